@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"regexp"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+// certificateExpiry returns the earliest expiry of the PEM encoded certificates in data.
+// The second return value is false if data contains no certificate.
+func certificateExpiry(data []byte) (time.Time, bool, error) {
+	var (
+		earliest time.Time
+		found    bool
+	)
+
+	for {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, false, errs.Wrap(err, "cannot parse certificate")
+		}
+
+		if !found || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+			found = true
+		}
+	}
+
+	return earliest, found, nil
+}
+
+// discoverCertificates discovers the configs containing PEM certificates. The optional parameters are
+// a regular expression the config name must match and Docker label filters ("key" or "key=value").
+func (p *swarmPlugin) discoverCertificates(_ context.Context, params []string) (any, error) {
+	var (
+		namePattern *regexp.Regexp
+		filters     map[string][]string
+	)
+
+	if len(params) > 0 && params[0] != "" {
+		var err error
+
+		namePattern, err = regexp.Compile(params[0])
+		if err != nil {
+			return nil, errs.Wrap(err, "invalid regular expression "+params[0])
+		}
+	}
+
+	for _, label := range params[min(len(params), 1):] {
+		if label == "" {
+			continue
+		}
+
+		if filters == nil {
+			filters = map[string][]string{}
+		}
+
+		filters["label"] = append(filters["label"], label)
+	}
+
+	body, err := p.client.Query(configKind.path, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []SwarmObject
+	if err = json.Unmarshal(body, &configs); err != nil {
+		return nil, errs.Wrap(err, "cannot unmarshal JSON")
+	}
+
+	lldConfigs := make([]LLDCertificate, 0, len(configs))
+	for _, c := range configs {
+		if namePattern != nil && !namePattern.MatchString(c.Spec.Name) {
+			continue
+		}
+
+		// Configs without certificates are skipped. Configs whose certificates fail to parse are
+		// kept, so that their expiry item reports the parse error.
+		if _, found, cErr := certificateExpiry(c.Spec.Data); cErr == nil && !found {
+			continue
+		}
+
+		lldConfigs = append(lldConfigs, LLDCertificate{ID: c.ID, Name: c.Spec.Name})
+	}
+
+	return jsonResult(lldConfigs)
+}
+
+func (p *swarmPlugin) getCertificateExpiry(_ context.Context, params []string) (any, error) {
+	if len(params) != 1 {
+		return nil, errs.New("expected 1 parameter for certificate expiry")
+	}
+
+	object, err := p.findSwarmObject(configKind, params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := p.client.Query(configKind.path+"/"+object.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var config SwarmObject
+	if err = json.Unmarshal(body, &config); err != nil {
+		return nil, errs.Wrap(err, "cannot unmarshal JSON")
+	}
+
+	expiry, found, err := certificateExpiry(config.Spec.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, errs.New("no certificate found in config " + params[0])
+	}
+
+	return expiry.Unix(), nil
+}
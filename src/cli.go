@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"golang.zabbix.com/sdk/errs"
+	"golang.zabbix.com/sdk/metric"
+)
+
+// newCLIPlugin returns a plugin for evaluating metrics from the command line, without an agent.
+// The events log cursor is kept in memory, so the agent's cursor file is not touched.
+func newCLIPlugin(socketPath string) *swarmPlugin {
+	p := &swarmPlugin{
+		client: newClient(socketPath, defaultTimeout),
+		options: pluginOptions{
+			Timeout:       defaultTimeout,
+			SocketPath:    socketPath,
+			LogBytesLimit: defaultLogBytesLimit,
+		},
+	}
+	p.Logger = cliLogger{os.Stderr}
+	p.initMetrics()
+
+	return p
+}
+
+// cliOptions are the command line flags for running the plugin without an agent.
+type cliOptions struct {
+	testKey    string
+	list       bool
+	template   bool
+	socketPath string
+	// vanishedPolicy is the vanished service policy the template is generated for.
+	vanishedPolicy string
+}
+
+// enabled reports whether any command line mode was requested.
+func (o *cliOptions) enabled() bool {
+	return o.testKey != "" || o.list || o.template
+}
+
+// runCLI lists the metrics, writes the template or evaluates an item key for the command line flags.
+func runCLI(opts *cliOptions) error {
+	if err := validateVanishedPolicy(opts.vanishedPolicy); err != nil {
+		return err
+	}
+
+	p := newCLIPlugin(opts.socketPath)
+	p.options.VanishedServicePolicy = opts.vanishedPolicy
+
+	switch {
+	case opts.list:
+		return p.listMetrics(os.Stdout)
+	case opts.template:
+		return p.writeTemplate(os.Stdout)
+	default:
+		return p.runTest(opts.testKey, os.Stdout)
+	}
+}
+
+// runTest evaluates an item key such as swarm.service.replicas_running[web] and writes the result to w.
+func (p *swarmPlugin) runTest(itemKey string, w io.Writer) error {
+	key, params, err := parseItemKey(itemKey)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.Export(key, params, nil)
+	if err != nil {
+		return err
+	}
+
+	// Items like the events log have no value when there is nothing new
+	if res == nil {
+		_, err = fmt.Fprintln(w, "(no value)")
+	} else {
+		_, err = fmt.Fprintln(w, res)
+	}
+
+	if err != nil {
+		return errs.Wrap(err, "cannot write result")
+	}
+
+	return nil
+}
+
+// listMetrics writes the supported metric keys and their descriptions to w, sorted by key.
+func (p *swarmPlugin) listMetrics(w io.Writer) error {
+	metricSet := metric.MetricSet{}
+
+	for k, m := range p.metrics {
+		metricSet[string(k)] = m.metric
+	}
+
+	// List returns the keys and descriptions as consecutive elements
+	list := metricSet.List()
+
+	rows := make([]string, 0, len(list)/2)
+	for i := 0; i+1 < len(list); i += 2 {
+		rows = append(rows, list[i]+"\t"+list[i+1])
+	}
+
+	sort.Strings(rows)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(tw, row)
+	}
+
+	err := tw.Flush()
+	if err != nil {
+		return errs.Wrap(err, "cannot write metrics list")
+	}
+
+	return nil
+}
+
+// parseItemKey splits a Zabbix item key into the metric key and its parameters. Parameters are
+// separated by commas and may be quoted to contain commas or brackets, with \" escaping a quote.
+func parseItemKey(itemKey string) (string, []string, error) {
+	itemKey = strings.TrimSpace(itemKey)
+
+	key, rest, hasParams := strings.Cut(itemKey, "[")
+	if key == "" {
+		return "", nil, errs.New("empty item key")
+	}
+
+	if !hasParams {
+		return key, nil, nil
+	}
+
+	if !strings.HasSuffix(rest, "]") {
+		return "", nil, errs.New("missing closing bracket in item key " + itemKey)
+	}
+
+	rest = rest[:len(rest)-1]
+
+	var (
+		params []string
+		param  strings.Builder
+		quoted bool
+		closed bool
+	)
+
+	for i := 0; i < len(rest); i++ {
+		c := rest[i]
+
+		switch {
+		case quoted && c == '\\' && i+1 < len(rest) && rest[i+1] == '"':
+			param.WriteByte('"')
+			i++
+		case quoted && c == '"':
+			quoted = false
+			closed = true
+		case quoted:
+			param.WriteByte(c)
+		case c == ',':
+			params = append(params, param.String())
+			param.Reset()
+			closed = false
+		case closed && c != ' ':
+			return "", nil, errs.New("unexpected character after quoted parameter in item key " + itemKey)
+		case c == '"' && param.Len() == 0:
+			quoted = true
+		case c == ' ' && param.Len() == 0, closed:
+			// Leading spaces and spaces after a quoted parameter are ignored
+		default:
+			param.WriteByte(c)
+		}
+	}
+
+	if quoted {
+		return "", nil, errs.New("unterminated quoted parameter in item key " + itemKey)
+	}
+
+	params = append(params, param.String())
+
+	return key, params, nil
+}
+
+// cliLogger writes plugin log messages to a writer, prefixed with the level.
+type cliLogger struct {
+	w io.Writer
+}
+
+func (l cliLogger) Tracef(string, ...any)               {}
+func (l cliLogger) Debugf(string, ...any)               {}
+func (l cliLogger) Warningf(format string, args ...any) { l.printf("warning", format, args...) }
+func (l cliLogger) Infof(format string, args ...any)    { l.printf("info", format, args...) }
+func (l cliLogger) Errf(format string, args ...any)     { l.printf("error", format, args...) }
+func (l cliLogger) Critf(format string, args ...any)    { l.printf("critical", format, args...) }
+
+func (l cliLogger) printf(level, format string, args ...any) {
+	_, _ = fmt.Fprintf(l.w, level+": "+format+"\n", args...)
+}
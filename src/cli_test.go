@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseItemKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		itemKey    string
+		wantKey    string
+		wantParams []string
+		wantErr    bool
+	}{
+		{"swarm.services.discovery", "swarm.services.discovery", nil, false},
+		{"swarm.service.replicas_running[web]", "swarm.service.replicas_running", []string{"web"}, false},
+		{"swarm.services.discovery[,,^prod]", "swarm.services.discovery", []string{"", "", "^prod"}, false},
+		{`swarm.service.log_errors[web, "(?i)error|fatal,panic"]`, "swarm.service.log_errors",
+			[]string{"web", "(?i)error|fatal,panic"}, false},
+		{`swarm.service.log_errors[web,"say \"hi\""]`, "swarm.service.log_errors", []string{"web", `say "hi"`}, false},
+		{"swarm.stack.health[]", "swarm.stack.health", []string{""}, false},
+		{"", "", nil, true},
+		{"swarm.stack.health[mystack", "", nil, true},
+		{`swarm.stack.health["mystack]`, "", nil, true},
+		{`swarm.stack.health["my"stack]`, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		key, params, err := parseItemKey(tt.itemKey)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseItemKey(%q) error = %v, wantErr %v", tt.itemKey, err, tt.wantErr)
+
+			continue
+		}
+
+		if key != tt.wantKey || !reflect.DeepEqual(params, tt.wantParams) {
+			t.Errorf("parseItemKey(%q) = %q, %q, want %q, %q", tt.itemKey, key, params, tt.wantKey, tt.wantParams)
+		}
+	}
+}
+
+func TestRunTest(t *testing.T) {
+	t.Parallel()
+
+	p := newCLIPlugin(newFakeDocker(t, "swarm").socketPath)
+
+	var out bytes.Buffer
+
+	err := p.runTest("swarm.service.replicas_desired[mystack_web]", &out)
+	if err != nil {
+		t.Fatalf("runTest() error = %v", err)
+	}
+
+	if out.String() != "3\n" {
+		t.Fatalf("runTest() output = %q, want %q", out.String(), "3\n")
+	}
+
+	if err = p.runTest("swarm.unknown", &out); err == nil {
+		t.Fatalf("runTest() with unknown key: expected error")
+	}
+}
+
+func TestListMetrics(t *testing.T) {
+	t.Parallel()
+
+	p := newCLIPlugin(defaultSocketPath)
+
+	var out bytes.Buffer
+
+	err := p.listMetrics(&out)
+	if err != nil {
+		t.Fatalf("listMetrics() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(p.metrics) {
+		t.Fatalf("listMetrics() printed %d lines, want %d", len(lines), len(p.metrics))
+	}
+
+	if !strings.HasPrefix(lines[0], "swarm.certificates.discovery ") {
+		t.Fatalf("listMetrics() first line = %q, want metrics sorted by key", lines[0])
+	}
+}
@@ -34,26 +34,72 @@ func newClient(socketPath string, timeout int) *client {
 }
 
 func (cli *client) Query(path string, filters map[string][]string) ([]byte, error) {
+	return cli.query(path, filters, nil)
+}
+
+// QueryStream is like query, but passes the response body to read instead of reading it
+// into memory. Use it for responses of unbounded size such as logs.
+func (cli *client) QueryStream(
+	path string, filters map[string][]string, params url.Values, read func(io.Reader) error,
+) error {
+	resp, err := cli.get(path, filters, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return read(resp.Body)
+}
+
+// query is like Query, with additional query parameters such as "since" and "until".
+func (cli *client) query(path string, filters map[string][]string, params url.Values) ([]byte, error) {
+	resp, err := cli.get(path, filters, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errs.Wrap(err, "cannot fetch data")
+	}
+
+	return body, nil
+}
+
+// get sends a request to the Docker API and returns the response, or the API error message if
+// the request failed.
+func (cli *client) get(path string, filters map[string][]string, params url.Values) (*http.Response, error) {
 	u := url.URL{
 		Scheme: "http",
 		Host:   "localhost", // host is irrelevant for unix sockets
 		Path:   dockerAPIVersion + "/" + path,
 	}
 
+	q := url.Values{}
+	for k, v := range params {
+		q[k] = v
+	}
+
 	if filters != nil {
 		filterJSON, err := json.Marshal(filters)
 		if err != nil {
 			return nil, errs.Wrap(err, "cannot marshal JSON")
 		}
-		q := u.Query()
 		q.Set("filters", string(filterJSON))
-		u.RawQuery = q.Encode()
 	}
 
+	u.RawQuery = q.Encode()
+
 	resp, err := cli.client.Get(u.String())
 	if err != nil {
 		return nil, errs.Wrap(err, "cannot fetch data")
 	}
+
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
@@ -61,14 +107,11 @@ func (cli *client) Query(path string, filters map[string][]string) ([]byte, erro
 		return nil, errs.Wrap(err, "cannot fetch data")
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		var apiErr ErrorMessage
-		if err = json.Unmarshal(body, &apiErr); err != nil {
-			// If we can't parse the error, return the raw body.
-			return nil, errs.New(string(body))
-		}
-		return nil, errs.New(apiErr.Message)
+	var apiErr ErrorMessage
+	if err = json.Unmarshal(body, &apiErr); err != nil {
+		// If we can't parse the error, return the raw body.
+		return nil, errs.New(string(body))
 	}
 
-	return body, nil
+	return nil, errs.New(apiErr.Message)
 }
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+const (
+	// modeAuto uses the compose mode when the daemon is not a swarm member.
+	modeAuto = "auto"
+	// modeSwarm always monitors swarm services.
+	modeSwarm = "swarm"
+	// modeCompose always monitors Compose projects from their containers.
+	modeCompose = "compose"
+
+	// modeCheckInterval is how long the detected mode is kept before asking the daemon again.
+	modeCheckInterval = time.Minute
+
+	composeServiceLabel = "com.docker.compose.service"
+	composeNumberLabel  = "com.docker.compose.container-number"
+	composeOneoffLabel  = "com.docker.compose.oneoff"
+
+	// composeLabelPrefix is the prefix of the labels Compose sets on the containers it creates.
+	composeLabelPrefix = "com.docker.compose."
+)
+
+// daemonMode caches whether the daemon is monitored in compose mode.
+type daemonMode struct {
+	mu      sync.Mutex
+	compose bool
+	checked time.Time
+}
+
+// inComposeMode reports whether services and tasks are emulated from the containers of Compose
+// projects, either because the compose mode is configured or, in auto mode, because the daemon is
+// not a swarm member.
+func (p *swarmPlugin) inComposeMode() (bool, error) {
+	switch p.options.Mode {
+	case modeSwarm:
+		return false, nil
+	case modeCompose:
+		return true, nil
+	}
+
+	p.mode.mu.Lock()
+	defer p.mode.mu.Unlock()
+
+	if !p.mode.checked.IsZero() && time.Since(p.mode.checked) < modeCheckInterval {
+		return p.mode.compose, nil
+	}
+
+	body, err := p.client.Query("info", nil)
+	if err != nil {
+		return false, err
+	}
+
+	var info Info
+	if err = json.Unmarshal(body, &info); err != nil {
+		return false, errs.Wrap(err, "cannot unmarshal JSON")
+	}
+
+	compose := info.Swarm.LocalNodeState == "inactive"
+	if compose != p.mode.compose || p.mode.checked.IsZero() {
+		p.Infof("daemon swarm state is %s, monitoring %s", info.Swarm.LocalNodeState, modeName(compose))
+	}
+
+	p.mode.compose = compose
+	p.mode.checked = time.Now()
+
+	return compose, nil
+}
+
+// withSwarmOnly wraps the handler of an item without an equivalent in compose mode to make the item
+// unsupported in compose mode, rather than returning values that look like real data.
+func (p *swarmPlugin) withSwarmOnly(
+	key swarmMetricKey, handler func(ctx context.Context, params []string) (any, error),
+) func(ctx context.Context, params []string) (any, error) {
+	return func(ctx context.Context, params []string) (any, error) {
+		compose, err := p.inComposeMode()
+		if err != nil {
+			return nil, err
+		}
+
+		if compose {
+			return nil, errs.New(string(key) + " is not supported in compose mode")
+		}
+
+		return handler(ctx, params)
+	}
+}
+
+func modeName(compose bool) string {
+	if compose {
+		return "Compose projects"
+	}
+
+	return "swarm services"
+}
+
+// getComposeContainers returns the containers of Compose projects, including stopped ones,
+// matching the Docker "label" filters.
+func (p *swarmPlugin) getComposeContainers(labels []string) ([]Container, error) {
+	filters := map[string][]string{"label": append([]string{composeProjectLabel}, labels...)}
+
+	body, err := p.client.query("containers/json", filters, url.Values{"all": {"true"}})
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []Container
+	if err = json.Unmarshal(body, &containers); err != nil {
+		return nil, errs.Wrap(err, "cannot unmarshal JSON")
+	}
+
+	result := make([]Container, 0, len(containers))
+
+	// Containers of docker compose run are not part of the service scale
+	for _, c := range containers {
+		if c.Labels[composeServiceLabel] != "" && c.Labels[composeOneoffLabel] != "True" {
+			result = append(result, c)
+		}
+	}
+
+	return result, nil
+}
+
+// composeServiceID returns the ID of the service emulated for the containers of a Compose service.
+func composeServiceID(c Container) string {
+	return c.Labels[composeProjectLabel] + "_" + c.Labels[composeServiceLabel]
+}
+
+// getComposeServices returns a replicated service for each Compose service, with the labels of
+// its most recent container except the ones set by Compose. Compose does not record the scale of a service, the desired replicas
+// are the highest container number, which is the scale the containers were created for, and at
+// least the number of containers. Only the "label" filter is supported.
+func (p *swarmPlugin) getComposeServices(filters map[string][]string) ([]Service, error) {
+	containers, err := p.getComposeContainers(filters["label"])
+	if err != nil {
+		return nil, err
+	}
+
+	type composeService struct {
+		newest, oldest Container
+		count          int
+		maxNumber      int
+	}
+
+	byID := map[string]*composeService{}
+
+	for _, c := range containers {
+		id := composeServiceID(c)
+
+		s, exists := byID[id]
+		if !exists {
+			s = &composeService{newest: c, oldest: c}
+			byID[id] = s
+		}
+
+		s.count++
+
+		if number, nErr := strconv.Atoi(c.Labels[composeNumberLabel]); nErr == nil && number > s.maxNumber {
+			s.maxNumber = number
+		}
+
+		if c.Created > s.newest.Created {
+			s.newest = c
+		}
+
+		if c.Created < s.oldest.Created {
+			s.oldest = c
+		}
+	}
+
+	services := make([]Service, 0, len(byID))
+
+	for id, s := range byID {
+		replicas := uint64(max(s.count, s.maxNumber)) //nolint:gosec // count and number are positive
+
+		service := Service{
+			ID:        id,
+			CreatedAt: containerTime(s.oldest.Created),
+			UpdatedAt: containerTime(s.newest.Created),
+		}
+		service.Spec.Name = s.newest.Labels[composeServiceLabel]
+		service.Spec.Labels = composeServiceLabels(s.newest.Labels)
+		service.Spec.Mode.Replicated = &ReplicatedService{Replicas: &replicas}
+
+		services = append(services, service)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].ID < services[j].ID
+	})
+
+	return services, nil
+}
+
+// composeServiceLabels returns the labels of a container without the labels Compose sets for its
+// own bookkeeping, like the config hash, except the project and service names the stack and
+// service key are resolved from.
+func composeServiceLabels(labels map[string]string) map[string]string {
+	result := make(map[string]string, len(labels))
+
+	for name, value := range labels {
+		if strings.HasPrefix(name, composeLabelPrefix) && name != composeProjectLabel && name != composeServiceLabel {
+			continue
+		}
+
+		result[name] = value
+	}
+
+	return result
+}
+
+// getComposeTasks returns a task for each container of a Compose service. The desired state of
+// all tasks is running. Only the "service" and "desired-state" filters are supported.
+func (p *swarmPlugin) getComposeTasks(filters map[string][]string) ([]Task, error) {
+	if states, ok := filters["desired-state"]; ok && !slices.Contains(states, "running") {
+		return []Task{}, nil
+	}
+
+	containers, err := p.getComposeContainers(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceIDs, byService := filters["service"]
+
+	tasks := make([]Task, 0, len(containers))
+
+	for _, c := range containers {
+		if byService && !slices.Contains(serviceIDs, composeServiceID(c)) {
+			continue
+		}
+
+		tasks = append(tasks, containerTask(c))
+	}
+
+	return tasks, nil
+}
+
+// containerTask returns the task equivalent of a container. A restarting container has failed
+// and waits to be restarted, an exited one completed or failed depending on its exit code.
+func containerTask(c Container) Task {
+	task := Task{
+		ID:           c.ID,
+		CreatedAt:    containerTime(c.Created),
+		ServiceID:    composeServiceID(c),
+		DesiredState: "running",
+	}
+
+	task.Status.ContainerStatus = &TaskContainerStatus{ContainerID: c.ID}
+
+	switch c.State {
+	case "running":
+		task.Status.State = "running"
+	case "created":
+		task.Status.State = "pending"
+	case "restarting":
+		task.Status.State = "failed"
+	case "exited":
+		// The status is like "Exited (1) 2 minutes ago"
+		var exitCode int
+		if _, err := fmt.Sscanf(c.Status, "Exited (%d)", &exitCode); err == nil {
+			task.Status.ContainerStatus.ExitCode = exitCode
+		}
+
+		task.Status.State = "complete"
+		if exitCode != 0 {
+			task.Status.State = "failed"
+		}
+	default:
+		task.Status.State = "shutdown"
+	}
+
+	return task
+}
+
+// containerTime formats a container creation time like the timestamps of the swarm API.
+func containerTime(created int64) string {
+	return time.Unix(created, 0).UTC().Format(time.RFC3339Nano)
+}
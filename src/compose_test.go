@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"maps"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestComposeDiscovery(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "compose"))
+
+	res, err := p.discoverServices(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("discoverServices() error = %v", err)
+	}
+
+	var services []map[string]string
+	if err = decodeResult(res, &services); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	keys := make([]string, 0, len(services))
+	for _, s := range services {
+		keys = append(keys, s["{#STACK.NAME}"]+"/"+s["{#SERVICE.KEY}"])
+	}
+
+	sort.Strings(keys)
+
+	// Containers of docker compose run and outside of Compose projects are not discovered
+	if want := "blog/blog_app,shop/shop_db,shop/shop_web"; strings.Join(keys, ",") != want {
+		t.Fatalf("discoverServices() = %v, want %s", keys, want)
+	}
+
+	res, err = p.discoverStacks(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("discoverStacks() error = %v", err)
+	}
+
+	var stacks []LLDStack
+	if err = decodeResult(res, &stacks); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	if len(stacks) != 2 {
+		t.Fatalf("discoverStacks() = %v, want blog and shop", stacks)
+	}
+}
+
+func TestComposeServiceLabels(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "compose"))
+
+	services, err := p.getComposeServices(nil)
+	if err != nil {
+		t.Fatalf("getComposeServices() error = %v", err)
+	}
+
+	for _, s := range services {
+		if s.ID != "shop_web" {
+			continue
+		}
+
+		want := map[string]string{
+			composeProjectLabel: "shop",
+			composeServiceLabel: "web",
+			"zabbix.team":       "payments",
+		}
+
+		if !maps.Equal(s.Spec.Labels, want) {
+			t.Fatalf("getComposeServices() labels = %v, want %v", s.Spec.Labels, want)
+		}
+
+		return
+	}
+
+	t.Fatalf("getComposeServices() = %v, want shop_web", services)
+}
+
+func TestComposeServiceHandlers(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "compose")
+	p := newTestPlugin(t, f)
+
+	handlers := map[string]func(context.Context, []string) (any, error){
+		"getDesiredReplicas": p.getDesiredReplicas,
+		"getRunningTasks":    p.getRunningTasks,
+		"getServiceRestarts": p.getServiceRestarts,
+		"getStackStatus":     p.getStackStatus,
+	}
+
+	tests := []struct {
+		handler string
+		param   string
+		want    int
+	}{
+		{"getDesiredReplicas", "shop_web", 3},
+		{"getRunningTasks", "shop_web", 2},
+		{"getServiceRestarts", "shop_web", 1},
+		{"getDesiredReplicas", "shop_db", 1},
+		{"getRunningTasks", "shop_db", 1},
+		{"getRunningTasks", "blog_app", 0},
+		{"getStackStatus", "shop", stackStatusDegraded},
+		{"getStackStatus", "blog", stackStatusCritical},
+	}
+
+	for _, tt := range tests {
+		res, err := handlers[tt.handler](context.Background(), []string{tt.param})
+		if err != nil {
+			t.Fatalf("%s(%s) error = %v", tt.handler, tt.param, err)
+		}
+
+		if res != tt.want {
+			t.Errorf("%s(%s) = %v, want %v", tt.handler, tt.param, res, tt.want)
+		}
+	}
+
+	// The detected mode is cached
+	if f.requestCount("info") != 1 {
+		t.Errorf("made %d info requests, want 1", f.requestCount("info"))
+	}
+
+	if f.requestCount("services") != 0 || f.requestCount("tasks") != 0 {
+		t.Errorf("made swarm requests in compose mode")
+	}
+}
+
+func TestComposeServiceEvents(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "compose")
+	p := newTestPlugin(t, f)
+
+	res, err := p.getServiceEvents(context.Background(), []string{"shop_web", "1000w"})
+	if err != nil {
+		t.Fatalf("getServiceEvents() error = %v", err)
+	}
+
+	var events ServiceEvents
+	if err = decodeResult(res, &events); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	// Only the container events of the Compose service are counted
+	if events.Count != 1 || events.Events[0].ID != "ctrweb0003" || events.Events[0].ExitCode != "1" {
+		t.Fatalf("getServiceEvents() = %+v, want the die event of ctrweb0003", events)
+	}
+
+	if f.requestCount("events") != 1 {
+		t.Errorf("made %d events requests, want 1 for container events", f.requestCount("events"))
+	}
+}
+
+func TestComposeSwarmOnlyItems(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "compose"))
+	p.initMetrics()
+
+	tests := []struct {
+		key    swarmMetricKey
+		params []string
+	}{
+		{networkDiscoveryMetric, nil},
+		{secretDiscoveryMetric, nil},
+		{configDiscoveryMetric, nil},
+		{certDiscoveryMetric, nil},
+		{portDiscoveryMetric, nil},
+		{servicePortCheck, []string{"shop_web", "80"}},
+		{serviceLogErrors, []string{"shop_web"}},
+	}
+
+	for _, tt := range tests {
+		res, err := p.metrics[tt.key].handler(context.Background(), tt.params)
+		if err == nil || !strings.Contains(err.Error(), "not supported in compose mode") {
+			t.Errorf("%s = %v, %v, want not supported in compose mode", tt.key, res, err)
+		}
+	}
+
+	// Items with a compose equivalent are not affected
+	if _, err := p.metrics[serviceReplicasDesired].handler(context.Background(), []string{"shop_web"}); err != nil {
+		t.Errorf("%s error = %v", serviceReplicasDesired, err)
+	}
+}
+
+func TestContainerTask(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		state    string
+		status   string
+		want     string
+		exitCode int
+	}{
+		{"running", "Up 2 hours", "running", 0},
+		{"created", "Created", "pending", 0},
+		{"restarting", "Restarting (1) 3 seconds ago", "failed", 0},
+		{"exited", "Exited (0) 1 hour ago", "complete", 0},
+		{"exited", "Exited (137) 1 hour ago", "failed", 137},
+		{"dead", "Dead", "shutdown", 0},
+	}
+
+	for _, tt := range tests {
+		c := Container{ID: "ctr", State: tt.state, Status: tt.status, Labels: map[string]string{
+			composeProjectLabel: "shop",
+			composeServiceLabel: "web",
+		}}
+
+		task := containerTask(c)
+		if task.Status.State != tt.want || task.Status.ContainerStatus.ExitCode != tt.exitCode {
+			t.Errorf("containerTask(%s, %s) = %s (exit code %d), want %s (exit code %d)", tt.state, tt.status,
+				task.Status.State, task.Status.ContainerStatus.ExitCode, tt.want, tt.exitCode)
+		}
+
+		if task.ServiceID != "shop_web" || task.DesiredState != "running" {
+			t.Errorf("containerTask() service = %s, desired state = %s", task.ServiceID, task.DesiredState)
+		}
+	}
+}
+
+func TestComposeCollectMetrics(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "compose")
+	p := newTestPlugin(t, f)
+
+	families, err := p.collectMetrics()
+	if err != nil {
+		t.Fatalf("collectMetrics() error = %v", err)
+	}
+
+	for _, family := range families {
+		if strings.HasPrefix(family.name, "swarm_node_") && len(family.samples) > 0 {
+			t.Errorf("collectMetrics() returned node samples in compose mode: %s", family.name)
+		}
+	}
+
+	if f.requestCount("nodes") != 0 {
+		t.Errorf("collectMetrics() queried nodes in compose mode")
+	}
+}
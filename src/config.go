@@ -0,0 +1,172 @@
+package main
+
+import (
+	"strings"
+
+	"golang.zabbix.com/sdk/conf"
+	"golang.zabbix.com/sdk/errs"
+	"golang.zabbix.com/sdk/plugin"
+)
+
+const (
+	defaultSocketPath    = "/var/run/docker.sock"
+	defaultTimeout       = 30
+	defaultLogBytesLimit = 10 << 20
+	defaultStackLabel    = "com.docker.stack.namespace"
+	defaultStandalone    = "standalone"
+)
+
+var _ plugin.Configurator = (*swarmPlugin)(nil)
+
+// pluginOptions are the options read from the Plugins.DockerSwarm.* section of the agent configuration.
+type pluginOptions struct {
+	// System contains obligatory options for loadable plugins.
+	System plugin.SystemOptions `conf:"optional,name=System"`
+
+	// Timeout is the maximum time in seconds to wait for the Docker API.
+	Timeout int `conf:"optional,range=1:30"`
+
+	// SocketPath is the path of the Docker daemon unix socket.
+	SocketPath string `conf:"optional,default=/var/run/docker.sock"`
+
+	// LabelMacros is a comma separated list of service label names exposed as LLD macros.
+	// A trailing "*" matches by prefix (e.g. "zabbix.*"). Empty exposes all labels.
+	LabelMacros string `conf:"optional"`
+
+	// EventsCursorFile is the file the position of the events log is persisted to.
+	// Empty keeps the position in memory only.
+	EventsCursorFile string `conf:"optional,default=/var/lib/zabbix/docker-swarm-events.cursor"`
+
+	// LogBytesLimit is the maximum number of bytes of service logs searched by a single check, the
+	// most recent logs are kept.
+	LogBytesLimit int64 `conf:"optional,range=1024:1073741824,default=10485760"`
+
+	// MetricsListen is the address of an HTTP listener exposing the swarm state in the OpenMetrics
+	// format on /metrics, e.g. "127.0.0.1:9323". Empty disables the listener.
+	MetricsListen string `conf:"optional"`
+
+	// Mode is "swarm" to monitor swarm services, "compose" to monitor Compose projects from their
+	// containers, or "auto" to use the compose mode when the daemon is not a swarm member.
+	Mode string `conf:"optional,default=auto"`
+
+	// StackLabel is the service label holding the stack name. Services without it are grouped by
+	// their Compose project label if set.
+	StackLabel string `conf:"optional,default=com.docker.stack.namespace"`
+
+	// StandaloneStackName is the name of the pseudo-stack of the services that belong to no stack.
+	StandaloneStackName string `conf:"optional,default=standalone"`
+
+	// VanishedServicePolicy is what service items return when their service was removed: "error"
+	// makes them unsupported, "negative" returns -1 and "last" returns the last known value.
+	VanishedServicePolicy string `conf:"optional,default=error"`
+
+	// VanishedServiceGracePeriod is the number of seconds after the removal of a service during
+	// which VanishedServicePolicy applies, the items are unsupported afterwards.
+	VanishedServiceGracePeriod int `conf:"optional,range=0:604800,default=600"`
+}
+
+// Configure implements the Configurator interface.
+// Initializes configuration structures.
+func (p *swarmPlugin) Configure(global *plugin.GlobalOptions, options any) {
+	err := conf.UnmarshalStrict(options, &p.options)
+	if err != nil {
+		p.Errf("cannot unmarshal configuration options: %s", err.Error())
+	}
+
+	if p.options.Timeout == 0 {
+		p.options.Timeout = global.Timeout
+	}
+
+	if p.options.SocketPath == "" {
+		p.options.SocketPath = defaultSocketPath
+	}
+
+	if p.options.LogBytesLimit == 0 {
+		p.options.LogBytesLimit = defaultLogBytesLimit
+	}
+
+	if mErr := validateMode(p.options.Mode); mErr != nil {
+		p.Errf("%s, using %s", mErr.Error(), modeAuto)
+
+		p.options.Mode = modeAuto
+	}
+
+	if vErr := validateVanishedPolicy(p.options.VanishedServicePolicy); vErr != nil {
+		p.Errf("%s, using %s", vErr.Error(), vanishedPolicyError)
+
+		p.options.VanishedServicePolicy = vanishedPolicyError
+	}
+
+	p.client = newClient(p.options.SocketPath, p.options.Timeout)
+
+	if p.options.MetricsListen != "" && p.metricsServer == nil {
+		addr, lErr := p.startMetricsServer(p.options.MetricsListen)
+		if lErr != nil {
+			p.Errf("cannot start metrics listener: %s", lErr.Error())
+
+			return
+		}
+
+		p.Infof("exposing metrics on http://%s%s", addr.String(), metricsPath)
+	}
+}
+
+// Validate implements the Configurator interface.
+// Returns an error if validation of a plugin's configuration is failed.
+func (p *swarmPlugin) Validate(options any) error {
+	var opts pluginOptions
+
+	err := conf.UnmarshalStrict(options, &opts)
+	if err != nil {
+		return errs.Wrap(err, "cannot unmarshal configuration options")
+	}
+
+	err = validateMode(opts.Mode)
+	if err != nil {
+		return err
+	}
+
+	return validateVanishedPolicy(opts.VanishedServicePolicy)
+}
+
+// validateMode returns an error for an unknown mode. Empty is the auto mode.
+func validateMode(mode string) error {
+	switch mode {
+	case "", modeAuto, modeSwarm, modeCompose:
+		return nil
+	default:
+		return errs.New("invalid mode " + mode + ", expected auto, swarm or compose")
+	}
+}
+
+// labelPatterns splits the LabelMacros option into its individual patterns.
+func (o *pluginOptions) labelPatterns() []string {
+	var patterns []string
+
+	for _, pattern := range strings.Split(o.LabelMacros, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+// stackLabel returns the StackLabel option or its default if unset.
+func (o *pluginOptions) stackLabel() string {
+	if o.StackLabel == "" {
+		return defaultStackLabel
+	}
+
+	return o.StackLabel
+}
+
+// standaloneStackName returns the StandaloneStackName option or its default if unset.
+func (o *pluginOptions) standaloneStackName() string {
+	if o.StandaloneStackName == "" {
+		return defaultStandalone
+	}
+
+	return o.StandaloneStackName
+}
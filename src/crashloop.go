@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+const (
+	defaultCrashLoopFailures = 3
+	defaultCrashLoopWindow   = 10 * time.Minute
+)
+
+// failedTaskStates are the task states counted as failures by the crash loop detection.
+var failedTaskStates = map[string]bool{
+	"failed":   true,
+	"rejected": true,
+	"orphaned": true,
+}
+
+// getServiceCrashLoop reports whether a service had at least <failures> task failures within
+// <window> and whether a replacement task is waiting for the restart delay (backoff).
+func (p *swarmPlugin) getServiceCrashLoop(_ context.Context, params []string) (any, error) {
+	if len(params) < 1 || len(params) > 3 {
+		return nil, errs.New("expected 1 to 3 parameters for service crash loop")
+	}
+
+	threshold := defaultCrashLoopFailures
+	if len(params) > 1 && params[1] != "" {
+		var err error
+
+		threshold, err = strconv.Atoi(params[1])
+		if err != nil || threshold < 1 {
+			return nil, errs.New("invalid failure threshold " + params[1])
+		}
+	}
+
+	var window string
+	if len(params) > 2 {
+		window = params[2]
+	}
+
+	duration, err := parseWindow(window, defaultCrashLoopWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	targetService, err := p.findServiceByIdentifier(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	filters := map[string][]string{
+		"service": {targetService.ID},
+	}
+
+	tasks, err := p.getTasks(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	since := time.Now().Add(-duration)
+
+	var result CrashLoop
+
+	for _, task := range tasks {
+		// A replacement task waits in the ready desired state until the restart delay has passed
+		if task.DesiredState == "ready" {
+			result.Backoff = 1
+		}
+
+		if !failedTaskStates[task.Status.State] {
+			continue
+		}
+
+		timestamp, pErr := time.Parse(time.RFC3339Nano, task.Status.Timestamp)
+		if pErr != nil || timestamp.Before(since) {
+			continue
+		}
+
+		result.Failures++
+
+		if timestamp.Unix() > result.LastFailure {
+			result.LastFailure = timestamp.Unix()
+			result.LastError = task.Status.Err
+		}
+	}
+
+	if result.Failures >= threshold {
+		result.CrashLoop = 1
+	}
+
+	return jsonResult(result)
+}
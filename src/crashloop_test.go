@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetServiceCrashLoop(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	tests := []struct {
+		params []string
+		want   CrashLoop
+	}{
+		{[]string{"db", "1", "1000w"}, CrashLoop{
+			CrashLoop:   1,
+			Failures:    1,
+			Backoff:     1,
+			LastFailure: time.Date(2024, 3, 6, 7, 58, 0, 0, time.UTC).Unix(),
+			LastError:   "task: non-zero exit (137)",
+		}},
+		{[]string{"db", "2", "1000w"}, CrashLoop{
+			Failures:    1,
+			Backoff:     1,
+			LastFailure: time.Date(2024, 3, 6, 7, 58, 0, 0, time.UTC).Unix(),
+			LastError:   "task: non-zero exit (137)",
+		}},
+		// The failures are older than the default window
+		{[]string{"web"}, CrashLoop{}},
+	}
+
+	for _, tt := range tests {
+		res, err := p.getServiceCrashLoop(context.Background(), tt.params)
+		if err != nil {
+			t.Fatalf("getServiceCrashLoop(%v) error = %v", tt.params, err)
+		}
+
+		var got CrashLoop
+		if err = decodeResult(res, &got); err != nil {
+			t.Fatalf("cannot decode result: %s", err)
+		}
+
+		if got != tt.want {
+			t.Errorf("getServiceCrashLoop(%v) = %+v, want %+v", tt.params, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+// defaultLogEventTypes are the event types returned by the events log when no types are given.
+var defaultLogEventTypes = []string{"service", "node", "secret", "config"}
+
+// eventCursors are the positions of the events log per set of event types, the time in
+// nanoseconds up to which events have been returned. Each item key has its own position, so items
+// with different types do not consume each other's events. The positions are kept in memory and
+// persisted to a file to survive agent restarts.
+type eventCursors struct {
+	mu        sync.Mutex
+	positions map[string]int64
+	loaded    bool
+}
+
+// load reads the cursor positions from path once. A missing file leaves the positions unset. The
+// file has one "<types> <position>" line per set of event types, a line with only a position is
+// the position of the default types written by earlier versions.
+func (c *eventCursors) load(path string) error {
+	if c.positions == nil {
+		c.positions = map[string]int64{}
+	}
+
+	if c.loaded || path == "" {
+		return nil
+	}
+
+	c.loaded = true
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return errs.Wrap(err, "cannot read events cursor")
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, " ")
+		if !found {
+			key, value = eventCursorKey(defaultLogEventTypes), key
+		}
+
+		position, pErr := strconv.ParseInt(value, 10, 64)
+		if pErr != nil {
+			return errs.Wrap(pErr, "cannot parse events cursor")
+		}
+
+		c.positions[key] = position
+	}
+
+	return nil
+}
+
+// store sets the cursor position of a set of event types and writes all positions to path.
+func (c *eventCursors) store(path, key string, position int64) error {
+	c.positions[key] = position
+
+	if path == "" {
+		return nil
+	}
+
+	lines := make([]string, 0, len(c.positions))
+	for k, v := range c.positions {
+		lines = append(lines, k+" "+strconv.FormatInt(v, 10)+"\n")
+	}
+
+	sort.Strings(lines)
+
+	err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0o600)
+	if err != nil {
+		return errs.Wrap(err, "cannot write events cursor")
+	}
+
+	return nil
+}
+
+// eventCursorKey returns the cursor key of a set of event types, independent of their order.
+func eventCursorKey(types []string) string {
+	sorted := slices.Clone(types)
+	sort.Strings(sorted)
+
+	return strings.Join(slices.Compact(sorted), ",")
+}
+
+// getEventsLog returns the events since the previous call with the same event types, one line per
+// event. No value is returned when there are no new events. The first call only initializes the
+// cursor.
+func (p *swarmPlugin) getEventsLog(_ context.Context, params []string) (any, error) {
+	types := make([]string, 0, len(params))
+	for _, t := range params {
+		if t != "" {
+			types = append(types, t)
+		}
+	}
+
+	if len(types) == 0 {
+		types = defaultLogEventTypes
+	}
+
+	key := eventCursorKey(types)
+
+	p.eventCursors.mu.Lock()
+	defer p.eventCursors.mu.Unlock()
+
+	err := p.eventCursors.load(p.options.EventsCursorFile)
+	if err != nil {
+		p.Warningf("%s, starting events log from now", err.Error())
+	}
+
+	until := time.Now()
+	previous := p.eventCursors.positions[key]
+
+	if previous == 0 {
+		err = p.eventCursors.store(p.options.EventsCursorFile, key, until.UnixNano())
+		if err != nil {
+			p.Warningf("%s", err.Error())
+		}
+
+		// No value until the cursor is initialized
+		return nil, nil
+	}
+
+	events, err := p.getEvents(time.Unix(0, previous), until, map[string][]string{"type": types})
+	if err != nil {
+		return nil, err
+	}
+
+	serviceKeys := map[string]string{}
+
+	if services, sErr := p.getServices(nil); sErr == nil {
+		for _, s := range services {
+			_, serviceKeys[s.ID] = p.resolveService(s.Spec.Name, s.Spec.Labels)
+		}
+	}
+
+	lines := make([]string, 0, len(events))
+	for _, event := range events {
+		// The since parameter is inclusive, events at the cursor were returned by the previous call
+		if event.TimeNano <= previous {
+			continue
+		}
+
+		lines = append(lines, formatLogEvent(event, serviceKeys))
+	}
+
+	err = p.eventCursors.store(p.options.EventsCursorFile, key, until.UnixNano())
+	if err != nil {
+		p.Warningf("%s", err.Error())
+	}
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// formatLogEvent formats an event as a single log line with the event type, action, service key and node.
+func formatLogEvent(event Event, serviceKeys map[string]string) string {
+	attributes := event.Actor.Attributes
+
+	fields := []string{
+		time.Unix(0, event.TimeNano).UTC().Format(time.RFC3339Nano),
+		"type=" + event.Type,
+		"action=" + strings.TrimSpace(event.Action),
+	}
+
+	serviceID := attributes["com.docker.swarm.service.id"]
+	serviceName := attributes["com.docker.swarm.service.name"]
+
+	if event.Type == "service" {
+		serviceID = event.Actor.ID
+		serviceName = attributes["name"]
+	}
+
+	if serviceID != "" {
+		key, ok := serviceKeys[serviceID]
+		if !ok {
+			// Removed services are no longer listed, fall back to the name from the event
+			key = serviceName
+		}
+
+		fields = append(fields, "service="+key)
+	}
+
+	node := attributes["com.docker.swarm.node.id"]
+	if event.Type == "node" {
+		node = event.Actor.ID
+	}
+
+	if node != "" {
+		fields = append(fields, "node="+node)
+	}
+
+	fields = append(fields, "id="+event.Actor.ID)
+
+	if name := attributes["name"]; name != "" {
+		fields = append(fields, "name="+name)
+	}
+
+	// Scaling a service reports the old and new replica counts
+	if replicas := attributes["replicas.new"]; replicas != "" {
+		fields = append(fields, "replicas="+attributes["replicas.old"]+"->"+replicas)
+	}
+
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+const (
+	defaultEventWindow = time.Hour
+	defaultEventLimit  = 10
+)
+
+// serviceContainerEvents are the container actions reported in the service events.
+var serviceContainerEvents = []string{"create", "die", "kill", "oom", "health_status"}
+
+// parseWindow parses a time window in seconds or with a Zabbix time suffix (s, m, h, d, w),
+// e.g. "600", "10m" or "1h". An empty window returns the default.
+func parseWindow(window string, defaultWindow time.Duration) (time.Duration, error) {
+	if window == "" {
+		return defaultWindow, nil
+	}
+
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+
+	unit := time.Second
+	number := window
+
+	if u, ok := units[window[len(window)-1]]; ok {
+		unit = u
+		number = window[:len(window)-1]
+	}
+
+	value, err := strconv.ParseUint(number, 10, 32)
+	if err != nil || value == 0 {
+		return 0, errs.New("invalid time window " + window)
+	}
+
+	return time.Duration(value) * unit, nil
+}
+
+// getEvents returns the events between since and until. Setting until makes the Docker API
+// return the stored events and close the stream instead of waiting for new events.
+func (p *swarmPlugin) getEvents(since, until time.Time, filters map[string][]string) ([]Event, error) {
+	params := url.Values{}
+	params.Set("since", formatEventTime(since))
+	params.Set("until", formatEventTime(until))
+
+	body, err := p.client.query("events", filters, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Events are streamed as a sequence of JSON objects, not as an array
+	var events []Event
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var event Event
+
+		err = decoder.Decode(&event)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, errs.Wrap(err, "cannot unmarshal JSON")
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// formatEventTime formats a time as the seconds.nanoseconds timestamp accepted by the events API.
+func formatEventTime(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// getServiceEvents returns the number of service and container events of a service within a
+// window and the most recent of them.
+func (p *swarmPlugin) getServiceEvents(_ context.Context, params []string) (any, error) {
+	if len(params) < 1 || len(params) > 3 {
+		return nil, errs.New("expected 1 to 3 parameters for service events")
+	}
+
+	var window, limit string
+	if len(params) > 1 {
+		window = params[1]
+	}
+
+	if len(params) > 2 {
+		limit = params[2]
+	}
+
+	duration, err := parseWindow(window, defaultEventWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	maxEvents := defaultEventLimit
+	if limit != "" {
+		maxEvents, err = strconv.Atoi(limit)
+		if err != nil || maxEvents < 0 {
+			return nil, errs.New("invalid event limit " + limit)
+		}
+	}
+
+	service, err := p.findServiceByIdentifier(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now()
+	since := until.Add(-duration)
+
+	compose, err := p.inComposeMode()
+	if err != nil {
+		return nil, err
+	}
+
+	// Service and container events are queried separately, filters of different keys are combined
+	// with AND and the service filter does not apply to container events. Compose services only
+	// have container events, selected by the project and service labels.
+	var serviceEvents []Event
+
+	containerLabels := []string{"com.docker.swarm.service.id=" + service.ID}
+
+	if compose {
+		containerLabels = []string{
+			composeProjectLabel + "=" + service.Spec.Labels[composeProjectLabel],
+			composeServiceLabel + "=" + service.Spec.Labels[composeServiceLabel],
+		}
+	} else {
+		serviceEvents, err = p.getEvents(since, until, map[string][]string{
+			"type":    {"service"},
+			"service": {service.ID},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	containerEvents, err := p.getEvents(since, until, map[string][]string{
+		"type":  {"container"},
+		"event": serviceContainerEvents,
+		"label": containerLabels,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := append(serviceEvents, containerEvents...)
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].TimeNano > events[j].TimeNano
+	})
+
+	result := ServiceEvents{
+		Count:  len(events),
+		Events: make([]ServiceEvent, 0, min(len(events), maxEvents)),
+	}
+
+	for _, event := range events[:min(len(events), maxEvents)] {
+		result.Events = append(result.Events, ServiceEvent{
+			Time:     event.Time,
+			Type:     event.Type,
+			Action:   strings.TrimSpace(event.Action),
+			ID:       event.Actor.ID,
+			Name:     event.Actor.Attributes["name"],
+			ExitCode: event.Actor.Attributes["exitCode"],
+		})
+	}
+
+	return jsonResult(result)
+}
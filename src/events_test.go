@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		window  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", time.Hour, false},
+		{"600", 10 * time.Minute, false},
+		{"30s", 30 * time.Second, false},
+		{"10m", 10 * time.Minute, false},
+		{"2h", 2 * time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"1w", 7 * 24 * time.Hour, false},
+		{"0", 0, true},
+		{"m", 0, true},
+		{"-5m", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseWindow(tt.window, time.Hour)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseWindow(%q) = %v, %v, want %v, error %v", tt.window, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGetServiceEvents(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	res, err := p.getServiceEvents(context.Background(), []string{"web", "1000w", "2"})
+	if err != nil {
+		t.Fatalf("getServiceEvents() error = %v", err)
+	}
+
+	var events ServiceEvents
+	if err = decodeResult(res, &events); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	if events.Count != 3 || len(events.Events) != 2 {
+		t.Fatalf("getServiceEvents() count = %d with %d events, want 3 with 2", events.Count, len(events.Events))
+	}
+
+	if events.Events[0].Action != "update" || events.Events[1].Action != "die" || events.Events[1].ExitCode != "1" {
+		t.Fatalf("getServiceEvents() events = %+v, want update and die (exit code 1), newest first", events.Events)
+	}
+
+	res, err = p.getServiceEvents(context.Background(), []string{"web"})
+	if err != nil {
+		t.Fatalf("getServiceEvents() error = %v", err)
+	}
+
+	if err = decodeResult(res, &events); err != nil || events.Count != 0 {
+		t.Fatalf("getServiceEvents() within the last hour = %v, want no events", res)
+	}
+}
+
+func TestGetEventsLog(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+	p.options.EventsCursorFile = filepath.Join(t.TempDir(), "cursor")
+
+	// The first poll only initializes the cursor
+	res, err := p.getEventsLog(context.Background(), nil)
+	if err != nil || res != nil {
+		t.Fatalf("getEventsLog() first poll = %v, %v, want no value", res, err)
+	}
+
+	if _, err = os.Stat(p.options.EventsCursorFile); err != nil {
+		t.Fatalf("getEventsLog() did not persist the cursor: %s", err)
+	}
+
+	// Rewind the cursor to just after the first event
+	p.eventCursors.positions[eventCursorKey(defaultLogEventTypes)] = 1709640000000000000
+
+	res, err = p.getEventsLog(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("getEventsLog() error = %v", err)
+	}
+
+	lines := strings.Split(res.(string), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("getEventsLog() = %q, want the service update and the node update", res)
+	}
+
+	want := "2024-03-05T12:05:00Z type=service action=update service=mystack_web id=svcweb0001 name=web replicas=2->3"
+	if lines[0] != want {
+		t.Fatalf("getEventsLog() line = %q, want %q", lines[0], want)
+	}
+
+	if !strings.Contains(lines[1], "type=node action=update node=node000002") {
+		t.Fatalf("getEventsLog() line = %q, want the node update", lines[1])
+	}
+
+	res, err = p.getEventsLog(context.Background(), nil)
+	if err != nil || res != nil {
+		t.Fatalf("getEventsLog() without new events = %v, %v, want no value", res, err)
+	}
+}
+
+func TestGetEventsLogCursorPerTypes(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+	p.options.EventsCursorFile = filepath.Join(t.TempDir(), "cursor")
+
+	services := []string{"service"}
+	nodes := []string{"node"}
+
+	// The first polls only initialize the cursors
+	for _, params := range [][]string{services, nodes} {
+		if res, err := p.getEventsLog(context.Background(), params); err != nil || res != nil {
+			t.Fatalf("getEventsLog(%v) first poll = %v, %v, want no value", params, res, err)
+		}
+	}
+
+	// Rewind both cursors to just after the first event
+	p.eventCursors.positions["service"] = 1709640000000000000
+	p.eventCursors.positions["node"] = 1709640000000000000
+
+	res, err := p.getEventsLog(context.Background(), services)
+	if err != nil || !strings.Contains(res.(string), "type=service action=update") {
+		t.Fatalf("getEventsLog(service) = %v, %v, want the service update", res, err)
+	}
+
+	// Polling the service events does not consume the node events
+	res, err = p.getEventsLog(context.Background(), nodes)
+	if err != nil || !strings.Contains(res.(string), "type=node action=update") {
+		t.Fatalf("getEventsLog(node) = %v, %v, want the node update", res, err)
+	}
+
+	for _, params := range [][]string{services, nodes} {
+		if res, err = p.getEventsLog(context.Background(), params); err != nil || res != nil {
+			t.Fatalf("getEventsLog(%v) without new events = %v, %v, want no value", params, res, err)
+		}
+	}
+
+	// Both cursors are persisted and read back by a new plugin instance
+	var cursors eventCursors
+	if err = cursors.load(p.options.EventsCursorFile); err != nil {
+		t.Fatalf("cannot load cursors: %s", err)
+	}
+
+	if cursors.positions["service"] != p.eventCursors.positions["service"] ||
+		cursors.positions["node"] != p.eventCursors.positions["node"] {
+		t.Fatalf("loaded cursors = %v, want %v", cursors.positions, p.eventCursors.positions)
+	}
+}
+
+func TestEventCursorsLegacyFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cursor")
+	if err := os.WriteFile(path, []byte("1709640000000000000\n"), 0o600); err != nil {
+		t.Fatalf("cannot write cursor: %s", err)
+	}
+
+	var cursors eventCursors
+	if err := cursors.load(path); err != nil {
+		t.Fatalf("load() error = %s", err)
+	}
+
+	if got := cursors.positions[eventCursorKey([]string{"service", "node", "secret", "config"})]; got != 1709640000000000000 {
+		t.Fatalf("load() default types position = %d, want 1709640000000000000", got)
+	}
+}
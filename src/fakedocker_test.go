@@ -0,0 +1,429 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeFixture is the content of a fixture file in testdata. Objects are kept as raw JSON and
+// served unchanged, so the fake daemon does not depend on the fields modeled by the plugin.
+type fakeFixture struct {
+	Services []json.RawMessage `json:"services"`
+	Tasks    []json.RawMessage `json:"tasks"`
+	Nodes    []json.RawMessage `json:"nodes"`
+	Networks []json.RawMessage `json:"networks"`
+	Secrets  []json.RawMessage `json:"secrets"`
+	Configs  []json.RawMessage `json:"configs"`
+	Events   []json.RawMessage `json:"events"`
+	Logs     map[string]string `json:"logs"`
+	// Info is the system information, a swarm member if unset.
+	Info       json.RawMessage   `json:"info"`
+	Containers []json.RawMessage `json:"containers"`
+}
+
+// fakeDocker is a fake Docker Engine API server listening on a unix socket.
+type fakeDocker struct {
+	fixture    fakeFixture
+	socketPath string
+	// failures maps an API path (without version prefix) to the HTTP status code returned for it.
+	failures map[string]int
+
+	mu sync.Mutex
+	// requests records the paths and raw queries of all requests.
+	requests []string
+}
+
+// newFakeDocker starts a fake Docker daemon serving testdata/<fixture>.json.
+// The server is stopped when the test finishes.
+func newFakeDocker(t *testing.T, fixture string) *fakeDocker {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", fixture+".json"))
+	if err != nil {
+		t.Fatalf("cannot read fixture: %s", err)
+	}
+
+	f := &fakeDocker{failures: map[string]int{}}
+
+	err = json.Unmarshal(data, &f.fixture)
+	if err != nil {
+		t.Fatalf("cannot parse fixture: %s", err)
+	}
+
+	// t.TempDir() paths may exceed the unix socket path length limit
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatalf("cannot create socket directory: %s", err)
+	}
+
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	f.socketPath = filepath.Join(dir, "docker.sock")
+
+	listener, err := net.Listen("unix", f.socketPath)
+	if err != nil {
+		t.Fatalf("cannot listen on unix socket: %s", err)
+	}
+
+	server := &http.Server{Handler: f} //nolint:gosec // test server
+	go func() { _ = server.Serve(listener) }()
+
+	t.Cleanup(func() { _ = server.Close() })
+
+	return f
+}
+
+// newTestPlugin returns a plugin connected to the fake daemon.
+func newTestPlugin(t *testing.T, f *fakeDocker) *swarmPlugin {
+	t.Helper()
+
+	p := &swarmPlugin{
+		client: newClient(f.socketPath, 5),
+	}
+	p.Logger = testLogger{t}
+
+	return p
+}
+
+// fail makes the fake daemon return an error with the given status code for an API path.
+func (f *fakeDocker) fail(path string, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures[path] = status
+}
+
+// removeService removes a service from the fixture, as if it was removed from the swarm.
+func (f *fakeDocker) removeService(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	services := make([]json.RawMessage, 0, len(f.fixture.Services))
+
+	for _, raw := range f.fixture.Services {
+		var s Service
+		if json.Unmarshal(raw, &s) == nil && s.ID == id {
+			continue
+		}
+
+		services = append(services, raw)
+	}
+
+	f.fixture.Services = services
+}
+
+// addService adds a service to the fixture, as if it was created in the swarm.
+func (f *fakeDocker) addService(raw string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.fixture.Services = append(f.fixture.Services, json.RawMessage(raw))
+}
+
+// requestCount returns the number of requests received for an API path.
+func (f *fakeDocker) requestCount(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+
+	for _, r := range f.requests {
+		if strings.HasPrefix(r, path+"?") {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+dockerAPIVersion+"/")
+
+	f.mu.Lock()
+	f.requests = append(f.requests, path+"?"+r.URL.RawQuery)
+	status, failing := f.failures[path]
+	services := f.fixture.Services
+	f.mu.Unlock()
+
+	if failing {
+		writeJSON(w, status, map[string]string{"message": "fake failure of " + path})
+
+		return
+	}
+
+	filters := map[string][]string{}
+	if raw := r.URL.Query().Get("filters"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &filters); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "invalid filters"})
+
+			return
+		}
+	}
+
+	switch {
+	case path == "services":
+		writeJSON(w, http.StatusOK, filterObjects(services, filters, matchService))
+	case strings.HasPrefix(path, "services/") && strings.HasSuffix(path, "/logs"):
+		f.serveLogs(w, strings.TrimSuffix(strings.TrimPrefix(path, "services/"), "/logs"))
+	case path == "info":
+		f.serveInfo(w)
+	case path == "containers/json":
+		writeJSON(w, http.StatusOK, filterObjects(f.fixture.Containers, filters, matchContainer))
+	case path == "tasks":
+		writeJSON(w, http.StatusOK, filterObjects(f.fixture.Tasks, filters, matchTask))
+	case path == "nodes":
+		writeJSON(w, http.StatusOK, filterObjects(f.fixture.Nodes, filters, matchAll))
+	case path == "networks":
+		writeJSON(w, http.StatusOK, filterObjects(f.fixture.Networks, filters, matchNetwork))
+	case path == "secrets":
+		writeJSON(w, http.StatusOK, filterObjects(f.fixture.Secrets, filters, matchSwarmObject))
+	case path == "configs":
+		writeJSON(w, http.StatusOK, filterObjects(f.fixture.Configs, filters, matchSwarmObject))
+	case strings.HasPrefix(path, "configs/"):
+		f.serveObject(w, f.fixture.Configs, strings.TrimPrefix(path, "configs/"))
+	case path == "events":
+		f.serveEvents(w, r, filters)
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "page not found"})
+	}
+}
+
+func (f *fakeDocker) serveInfo(w http.ResponseWriter) {
+	if f.fixture.Info == nil {
+		writeJSON(w, http.StatusOK, map[string]any{"Swarm": map[string]string{"LocalNodeState": "active"}})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, f.fixture.Info)
+}
+
+func (f *fakeDocker) serveObject(w http.ResponseWriter, objects []json.RawMessage, id string) {
+	for _, raw := range objects {
+		var o struct {
+			ID string `json:"ID"`
+		}
+
+		if json.Unmarshal(raw, &o) == nil && o.ID == id {
+			writeJSON(w, http.StatusOK, raw)
+
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusNotFound, map[string]string{"message": "no such object: " + id})
+}
+
+// serveLogs writes the fixture logs of a service as a multiplexed stdout stream, one frame per line.
+func (f *fakeDocker) serveLogs(w http.ResponseWriter, serviceID string) {
+	logs, ok := f.fixture.Logs[serviceID]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "service " + serviceID + " not found"})
+
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	for _, line := range strings.SplitAfter(logs, "\n") {
+		if line == "" {
+			continue
+		}
+
+		header := make([]byte, 8)
+		header[0] = 1
+		binary.BigEndian.PutUint32(header[4:], uint32(len(line))) //nolint:gosec // test data
+
+		_, _ = w.Write(header)
+		_, _ = w.Write([]byte(line))
+	}
+}
+
+// serveEvents writes the fixture events within since and until as a stream of JSON objects.
+func (f *fakeDocker) serveEvents(w http.ResponseWriter, r *http.Request, filters map[string][]string) {
+	since := parseFakeEventTime(r.URL.Query().Get("since"))
+	until := parseFakeEventTime(r.URL.Query().Get("until"))
+
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+
+	for _, raw := range filterObjects(f.fixture.Events, filters, matchEvent) {
+		var event Event
+		if json.Unmarshal(raw, &event) != nil {
+			continue
+		}
+
+		if (since != 0 && event.TimeNano < since) || (until != 0 && event.TimeNano > until) {
+			continue
+		}
+
+		_ = encoder.Encode(raw)
+	}
+}
+
+// parseFakeEventTime parses a seconds.nanoseconds timestamp into nanoseconds, 0 if empty.
+func parseFakeEventTime(value string) int64 {
+	if value == "" {
+		return 0
+	}
+
+	seconds, nanos, _ := strings.Cut(value, ".")
+	s, _ := strconv.ParseInt(seconds, 10, 64)
+	n, _ := strconv.ParseInt(nanos, 10, 64)
+
+	return s*1e9 + n
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func filterObjects(
+	objects []json.RawMessage, filters map[string][]string, match func(json.RawMessage, map[string][]string) bool,
+) []json.RawMessage {
+	result := make([]json.RawMessage, 0, len(objects))
+
+	for _, raw := range objects {
+		if match(raw, filters) {
+			result = append(result, raw)
+		}
+	}
+
+	return result
+}
+
+// matchAny reports whether the filter key is unset or one of its values matches.
+func matchAny(filters map[string][]string, key string, match func(string) bool) bool {
+	values, ok := filters[key]
+	if !ok {
+		return true
+	}
+
+	for _, v := range values {
+		if match(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchLabels reports whether all "label" filters ("key" or "key=value") match the labels.
+func matchLabels(filters map[string][]string, labels map[string]string) bool {
+	for _, filter := range filters["label"] {
+		key, value, hasValue := strings.Cut(filter, "=")
+
+		actual, ok := labels[key]
+		if !ok || (hasValue && actual != value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchAll(json.RawMessage, map[string][]string) bool {
+	return true
+}
+
+func matchService(raw json.RawMessage, filters map[string][]string) bool {
+	var s Service
+	if json.Unmarshal(raw, &s) != nil {
+		return false
+	}
+
+	return matchLabels(filters, s.Spec.Labels) &&
+		matchAny(filters, "id", func(v string) bool { return strings.HasPrefix(s.ID, v) }) &&
+		matchAny(filters, "name", func(v string) bool { return s.Spec.Name == v })
+}
+
+func matchTask(raw json.RawMessage, filters map[string][]string) bool {
+	var task Task
+	if json.Unmarshal(raw, &task) != nil {
+		return false
+	}
+
+	return matchAny(filters, "service", func(v string) bool { return task.ServiceID == v }) &&
+		matchAny(filters, "desired-state", func(v string) bool { return task.DesiredState == v })
+}
+
+func matchContainer(raw json.RawMessage, filters map[string][]string) bool {
+	var c Container
+	if json.Unmarshal(raw, &c) != nil {
+		return false
+	}
+
+	return matchLabels(filters, c.Labels)
+}
+
+func matchNetwork(raw json.RawMessage, filters map[string][]string) bool {
+	var n Network
+	if json.Unmarshal(raw, &n) != nil {
+		return false
+	}
+
+	return matchAny(filters, "driver", func(v string) bool { return n.Driver == v })
+}
+
+func matchSwarmObject(raw json.RawMessage, filters map[string][]string) bool {
+	var o SwarmObject
+	if json.Unmarshal(raw, &o) != nil {
+		return false
+	}
+
+	return matchLabels(filters, o.Spec.Labels) &&
+		matchAny(filters, "name", func(v string) bool { return o.Spec.Name == v })
+}
+
+func matchEvent(raw json.RawMessage, filters map[string][]string) bool {
+	var event Event
+	if json.Unmarshal(raw, &event) != nil {
+		return false
+	}
+
+	return matchLabels(filters, event.Actor.Attributes) &&
+		matchAny(filters, "type", func(v string) bool { return event.Type == v }) &&
+		matchAny(filters, "service", func(v string) bool { return event.Type == "service" && event.Actor.ID == v }) &&
+		matchAny(filters, "event", func(v string) bool {
+			action, _, _ := strings.Cut(event.Action, ":")
+
+			return action == v
+		})
+}
+
+// testLogger forwards plugin log messages to the test log.
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Tracef(format string, args ...any)   { l.t.Logf("TRACE: "+format, args...) }
+func (l testLogger) Debugf(format string, args ...any)   { l.t.Logf("DEBUG: "+format, args...) }
+func (l testLogger) Warningf(format string, args ...any) { l.t.Logf("WARNING: "+format, args...) }
+func (l testLogger) Infof(format string, args ...any)    { l.t.Logf("INFO: "+format, args...) }
+func (l testLogger) Errf(format string, args ...any)     { l.t.Logf("ERROR: "+format, args...) }
+func (l testLogger) Critf(format string, args ...any)    { l.t.Logf("CRITICAL: "+format, args...) }
+
+// errUnexpected is returned by helpers when a handler result has an unexpected type.
+var errUnexpected = errors.New("unexpected result type")
+
+// decodeResult unmarshals a JSON string handler result into v.
+func decodeResult(res any, v any) error {
+	s, ok := res.(string)
+	if !ok {
+		return errUnexpected
+	}
+
+	return json.Unmarshal([]byte(s), v)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+const (
+	defaultLogWindow = 5 * time.Minute
+	// logFrameHeaderSize is the size of the header of a frame in a multiplexed log stream.
+	logFrameHeaderSize = 8
+)
+
+// demuxLogs writes the payload of a Docker multiplexed log stream to w. Each frame starts with an
+// 8 byte header: the stream type, 3 zero bytes and the big endian payload size. A truncated last
+// frame is written as far as it was read.
+func demuxLogs(r io.Reader, w io.Writer) error {
+	header := make([]byte, logFrameHeaderSize)
+
+	for {
+		_, err := io.ReadFull(r, header)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+
+		if err != nil {
+			return errs.Wrap(err, "cannot read logs")
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+
+		_, err = io.CopyN(w, r, size)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return errs.Wrap(err, "cannot read logs")
+		}
+	}
+}
+
+// logTail keeps the last limit bytes written to it, using at most twice the limit of memory.
+type logTail struct {
+	limit     int64
+	data      []byte
+	truncated bool
+}
+
+func (t *logTail) Write(b []byte) (int, error) {
+	t.data = append(t.data, b...)
+
+	if int64(len(t.data)) > 2*t.limit {
+		t.data = append(t.data[:0], t.data[int64(len(t.data))-t.limit:]...)
+		t.truncated = true
+	}
+
+	return len(b), nil
+}
+
+// bytes returns the kept bytes. If older bytes were dropped, the partial first line is dropped too.
+func (t *logTail) bytes() []byte {
+	data := t.data
+
+	if int64(len(data)) > t.limit {
+		data = data[int64(len(data))-t.limit:]
+		t.truncated = true
+	}
+
+	if t.truncated {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			data = data[i+1:]
+		}
+	}
+
+	return data
+}
+
+// getServiceLogErrors counts the log lines of a service within a window matching a regular expression.
+// Only the most recent LogBytesLimit bytes of logs within the window are searched.
+func (p *swarmPlugin) getServiceLogErrors(_ context.Context, params []string) (any, error) {
+	if len(params) < 2 || len(params) > 3 {
+		return nil, errs.New("expected 2 or 3 parameters for service log errors")
+	}
+
+	pattern, err := regexp.Compile(params[1])
+	if err != nil {
+		return nil, errs.Wrap(err, "invalid regular expression "+params[1])
+	}
+
+	var window string
+	if len(params) > 2 {
+		window = params[2]
+	}
+
+	duration, err := parseWindow(window, defaultLogWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := p.findServiceByIdentifier(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("stdout", "1")
+	query.Set("stderr", "1")
+	query.Set("since", strconv.FormatInt(time.Now().Add(-duration).Unix(), 10))
+
+	limit := p.options.LogBytesLimit
+	if limit <= 0 {
+		limit = defaultLogBytesLimit
+	}
+
+	tail := &logTail{limit: limit}
+
+	err = p.client.QueryStream("services/"+service.ID+"/logs", nil, query, func(r io.Reader) error {
+		// Logs of services with a TTY are not multiplexed
+		if service.Spec.TaskTemplate.ContainerSpec.TTY {
+			_, cErr := io.Copy(tail, r)
+			if cErr != nil {
+				return errs.Wrap(cErr, "cannot read logs")
+			}
+
+			return nil
+		}
+
+		return demuxLogs(r, tail)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	body := tail.bytes()
+
+	if tail.truncated {
+		p.Warningf("logs of service %s within the window exceed %d bytes, only the most recent %d bytes "+
+			"were searched", params[0], limit, limit)
+	}
+
+	count := 0
+
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if len(line) > 0 && pattern.Match(line) {
+			count++
+		}
+	}
+
+	return count, nil
+}
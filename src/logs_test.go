@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestDemuxLogs(t *testing.T) {
+	t.Parallel()
+
+	data := []byte{1, 0, 0, 0, 0, 0, 0, 3, 'o', 'k', '\n', 2, 0, 0, 0, 0, 0, 0, 10, 'e', 'r', 'r'}
+
+	var out bytes.Buffer
+	if err := demuxLogs(bytes.NewReader(data), &out); err != nil {
+		t.Fatalf("demuxLogs() error = %v", err)
+	}
+
+	if got := out.String(); got != "ok\nerr" {
+		t.Fatalf("demuxLogs() = %q, want %q", got, "ok\nerr")
+	}
+}
+
+func TestLogTail(t *testing.T) {
+	t.Parallel()
+
+	tail := &logTail{limit: 12}
+
+	for _, line := range []string{"first line\n", "second line\n", "third\n", "fourth\n"} {
+		_, _ = tail.Write([]byte(line))
+	}
+
+	// The last 12 bytes start within "third", the partial line is dropped
+	if got := string(tail.bytes()); got != "fourth\n" || !tail.truncated {
+		t.Fatalf("bytes() = %q, truncated %v, want %q, truncated", got, tail.truncated, "fourth\n")
+	}
+
+	short := &logTail{limit: 100}
+	_, _ = short.Write([]byte("one\ntwo\n"))
+
+	if got := string(short.bytes()); got != "one\ntwo\n" || short.truncated {
+		t.Fatalf("bytes() = %q, truncated %v, want all lines", got, short.truncated)
+	}
+}
+
+func TestGetServiceLogErrors(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{"(?i)error", 2},
+		{"ERROR", 1},
+		{" 200$", 2},
+	}
+
+	for _, tt := range tests {
+		res, err := p.getServiceLogErrors(context.Background(), []string{"web", tt.pattern})
+		if err != nil {
+			t.Fatalf("getServiceLogErrors() error = %v", err)
+		}
+
+		if res != tt.want {
+			t.Errorf("getServiceLogErrors(%q) = %v, want %v", tt.pattern, res, tt.want)
+		}
+	}
+
+	if _, err := p.getServiceLogErrors(context.Background(), []string{"web", "("}); err == nil {
+		t.Fatalf("getServiceLogErrors() with invalid regexp: expected error")
+	}
+}
+
+func TestGetServiceLogErrorsKeepsRecentLogs(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	// Only the last line fits, older lines are dropped rather than the most recent ones
+	p.options.LogBytesLimit = 20
+
+	res, err := p.getServiceLogErrors(context.Background(), []string{"web", "(?i)error"})
+	if err != nil {
+		t.Fatalf("getServiceLogErrors() error = %v", err)
+	}
+
+	if res != 1 {
+		t.Fatalf("getServiceLogErrors() = %v, want 1 for the most recent line", res)
+	}
+
+	res, err = p.getServiceLogErrors(context.Background(), []string{"web", "^GET"})
+	if err != nil || res != 0 {
+		t.Fatalf("getServiceLogErrors() of older lines = %v, %v, want 0", res, err)
+	}
+}
@@ -20,6 +20,8 @@ package main
 
 import (
 	"errors"
+	stdflag "flag"
+	"fmt"
 	"os"
 
 	"golang.zabbix.com/sdk/plugin/flag"
@@ -54,6 +56,17 @@ var (
 )
 
 func main() {
+	var cli cliOptions
+
+	// Registered before HandleFlags, which parses the command line flags
+	stdflag.StringVar(&cli.testKey, "test", "", "evaluate an item key against the Docker socket and exit, "+
+		"e.g. -test 'swarm.service.replicas_running[web]'")
+	stdflag.BoolVar(&cli.list, "list", false, "list the supported metric keys and exit")
+	stdflag.BoolVar(&cli.template, "template", false, "write a Zabbix template for all metric keys and exit")
+	stdflag.StringVar(&cli.socketPath, "socket", defaultSocketPath, "Docker socket used by -test")
+	stdflag.StringVar(&cli.vanishedPolicy, "vanished-policy", vanishedPolicyError,
+		"VanishedServicePolicy the -template is generated for, negative makes the service items float")
+
 	err := flag.HandleFlags(
 		Name,
 		os.Args[0],
@@ -71,6 +84,20 @@ func main() {
 		panic(err)
 	}
 
+	if !stdflag.Parsed() {
+		stdflag.Parse()
+	}
+
+	if cli.enabled() {
+		err = runCLI(&cli)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	err = Launch()
 	if err != nil {
 		panic(err)
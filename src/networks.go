@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/netip"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+// reservedSubnetAddresses are the network, broadcast and gateway addresses of a subnet.
+const reservedSubnetAddresses = 3
+
+func (p *swarmPlugin) getOverlayNetworks() ([]Network, error) {
+	body, err := p.client.Query("networks", map[string][]string{"driver": {"overlay"}})
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []Network
+	if err = json.Unmarshal(body, &networks); err != nil {
+		return nil, errs.Wrap(err, "cannot unmarshal JSON")
+	}
+
+	return networks, nil
+}
+
+func (p *swarmPlugin) discoverNetworks(_ context.Context, params []string) (any, error) {
+	if len(params) != 0 {
+		return nil, errs.New("expected no parameters for network discovery")
+	}
+
+	networks, err := p.getOverlayNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	lldNetworks := make([]LLDNetwork, 0, len(networks))
+	for _, n := range networks {
+		lldNetworks = append(lldNetworks, LLDNetwork{
+			ID:      n.ID,
+			Name:    n.Name,
+			Scope:   n.Scope,
+			Ingress: n.Ingress,
+		})
+	}
+
+	return jsonResult(lldNetworks)
+}
+
+func (p *swarmPlugin) getNetworkAddresses(_ context.Context, params []string) (any, error) {
+	if len(params) != 1 {
+		return nil, errs.New("expected 1 parameter for network addresses")
+	}
+
+	addresses, err := p.networkAddresses(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonResult(addresses)
+}
+
+func (p *swarmPlugin) getNetworkAddressesUsedPercent(_ context.Context, params []string) (any, error) {
+	if len(params) != 1 {
+		return nil, errs.New("expected 1 parameter for network address usage")
+	}
+
+	addresses, err := p.networkAddresses(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return addresses.UsedPercent, nil
+}
+
+// networkAddresses compares the size of the IPv4 subnets of an overlay network with the
+// addresses allocated to service virtual IPs and task attachments on that network.
+func (p *swarmPlugin) networkAddresses(identifier string) (*NetworkAddresses, error) {
+	networks, err := p.getOverlayNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	var network *Network
+
+	for i := range networks {
+		if networks[i].ID == identifier || networks[i].Name == identifier {
+			network = &networks[i]
+
+			break
+		}
+	}
+
+	if network == nil {
+		return nil, newNotFoundError("network", identifier)
+	}
+
+	var (
+		subnets  []netip.Prefix
+		gateways = make(map[netip.Addr]bool)
+		result   NetworkAddresses
+	)
+
+	for _, config := range network.IPAM.Config {
+		subnet, pErr := netip.ParsePrefix(config.Subnet)
+		if pErr != nil || !subnet.Addr().Is4() {
+			continue
+		}
+
+		subnets = append(subnets, subnet.Masked())
+		result.Total += max(1<<(32-subnet.Bits())-reservedSubnetAddresses, 0)
+
+		if gateway, gErr := netip.ParseAddr(config.Gateway); gErr == nil {
+			gateways[gateway] = true
+		}
+	}
+
+	if len(subnets) == 0 {
+		return nil, errs.New("no IPv4 subnet configured for network " + identifier)
+	}
+
+	services, err := p.getServices(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := p.getTasks(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	used := make(map[netip.Addr]bool)
+	allocate := func(address string) {
+		prefix, pErr := netip.ParsePrefix(address)
+		if pErr != nil || gateways[prefix.Addr()] {
+			return
+		}
+
+		for _, subnet := range subnets {
+			if subnet.Contains(prefix.Addr()) {
+				used[prefix.Addr()] = true
+
+				return
+			}
+		}
+	}
+
+	for _, s := range services {
+		for _, vip := range s.Endpoint.VirtualIPs {
+			if vip.NetworkID == network.ID {
+				allocate(vip.Addr)
+			}
+		}
+	}
+
+	for _, task := range tasks {
+		// Addresses of tasks that are shut down are released by the swarm
+		if task.DesiredState == "shutdown" || task.DesiredState == "remove" {
+			continue
+		}
+
+		for _, attachment := range task.NetworksAttachments {
+			if attachment.Network.ID != network.ID {
+				continue
+			}
+
+			for _, address := range attachment.Addresses {
+				allocate(address)
+			}
+		}
+	}
+
+	result.Used = len(used)
+	result.Free = max(result.Total-result.Used, 0)
+
+	if result.Total > 0 {
+		result.UsedPercent = float64(result.Used) / float64(result.Total) * 100
+	}
+
+	return &result, nil
+}
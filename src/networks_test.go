@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDiscoverNetworks(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	res, err := p.discoverNetworks(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("discoverNetworks() error = %v", err)
+	}
+
+	var lld []map[string]any
+	if err = decodeResult(res, &lld); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	if len(lld) != 2 {
+		t.Fatalf("discoverNetworks() returned %d networks, want the 2 overlay networks", len(lld))
+	}
+}
+
+func TestNetworkAddresses(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		network string
+		want    NetworkAddresses
+		wantErr bool
+	}{
+		// /28: 13 usable addresses, 2 service VIPs and 2 running task attachments
+		{"mystack_backend", NetworkAddresses{Total: 13, Used: 4, Free: 9, UsedPercent: 4.0 / 13 * 100}, false},
+		{"netingress", NetworkAddresses{Total: 253, Used: 1, Free: 252, UsedPercent: 1.0 / 253 * 100}, false},
+		{"bridge", NetworkAddresses{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			t.Parallel()
+
+			p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+			got, err := p.networkAddresses(tt.network)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("networkAddresses() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && *got != tt.want {
+				t.Fatalf("networkAddresses() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+const (
+	metricsPath            = "/metrics"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	textMetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	// metricsShutdownTimeout is how long the requests in progress are waited for when stopping.
+	metricsShutdownTimeout = 5 * time.Second
+)
+
+// metricFamily is a gauge with its samples in the OpenMetrics exposition.
+type metricFamily struct {
+	name    string
+	help    string
+	samples []metricSample
+}
+
+// metricSample is a single formatted value of a metric family with its label names and values.
+type metricSample struct {
+	labels [][2]string
+	value  string
+}
+
+// add appends a sample with labels given as alternating names and values.
+func (f *metricFamily) add(value float64, labels ...string) {
+	f.addValue(strconv.FormatFloat(value, 'g', -1, 64), labels...)
+}
+
+// addFlag appends a sample of 1 or 0 depending on b.
+func (f *metricFamily) addFlag(b bool, labels ...string) {
+	f.addValue(boolToFlag(b), labels...)
+}
+
+func (f *metricFamily) addValue(value string, labels ...string) {
+	sample := metricSample{value: value}
+	for i := 0; i+1 < len(labels); i += 2 {
+		sample.labels = append(sample.labels, [2]string{labels[i], labels[i+1]})
+	}
+
+	f.samples = append(f.samples, sample)
+}
+
+// startMetricsServer starts the HTTP listener exposing the swarm state on /metrics. The listener
+// runs until the plugin is stopped, independently of the Zabbix items being polled.
+func (p *swarmPlugin) startMetricsServer(address string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errs.Wrap(err, "cannot listen on "+address)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(metricsPath, p.serveMetrics)
+
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	done := make(chan struct{})
+
+	p.metricsServer = server
+	p.metricsDone = done
+
+	go func() {
+		defer close(done)
+
+		sErr := server.Serve(listener)
+		if sErr != nil && !errors.Is(sErr, http.ErrServerClosed) {
+			p.Errf("metrics listener failed: %s", sErr.Error())
+		}
+	}()
+
+	return listener.Addr(), nil
+}
+
+// stopMetricsServer closes the metrics listener, waiting for the requests in progress, so that the
+// address can be bound again when the plugin is started again.
+func (p *swarmPlugin) stopMetricsServer() {
+	if p.metricsServer == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+	defer cancel()
+
+	if err := p.metricsServer.Shutdown(ctx); err != nil {
+		p.Warningf("cannot stop metrics listener: %s", err.Error())
+	}
+
+	// Serve closes the listener when it returns, even if it had not started listening yet
+	<-p.metricsDone
+
+	p.metricsServer = nil
+	p.metricsDone = nil
+}
+
+// serveMetrics writes the swarm state in the OpenMetrics format, or in the Prometheus text format
+// if the client does not accept OpenMetrics.
+func (p *swarmPlugin) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	up := metricFamily{name: "swarm_up", help: "Whether the Docker API could be queried."}
+
+	families, err := p.collectMetrics()
+	if err != nil {
+		p.Warningf("cannot collect metrics: %s", err.Error())
+		up.addFlag(false)
+
+		families = nil
+	} else {
+		up.addFlag(true)
+	}
+
+	families = append([]*metricFamily{&up}, families...)
+
+	openMetrics := strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", openMetricsContentType)
+	} else {
+		w.Header().Set("Content-Type", textMetricsContentType)
+	}
+
+	writeMetrics(w, families, openMetrics)
+}
+
+// collectMetrics collects the state of the monitored services, their tasks, the stacks and the
+// nodes with the same evaluation as the Zabbix items. Services opted out of monitoring are skipped.
+func (p *swarmPlugin) collectMetrics() ([]*metricFamily, error) {
+	allServices, err := p.getServices(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make([]Service, 0, len(allServices))
+	for _, s := range allServices {
+		if isMonitored(s) {
+			services = append(services, s)
+		}
+	}
+
+	var tasks []Task
+
+	// Without service IDs the tasks of all services would be returned
+	if len(services) > 0 {
+		tasks, err = p.getServicesTasks(services)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	compose, err := p.inComposeMode()
+	if err != nil {
+		return nil, err
+	}
+
+	// A daemon monitored in compose mode has no nodes
+	var nodes []Node
+
+	if !compose {
+		nodes, err = p.getNodes()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	serviceFamilies, stacks := p.collectServiceMetrics(services, tasks)
+	stackFamilies := p.collectStackMetrics(stacks, tasks)
+	nodeFamilies := collectNodeMetrics(nodes, tasks)
+
+	return append(append(serviceFamilies, stackFamilies...), nodeFamilies...), nil
+}
+
+// collectServiceMetrics returns the service metric families and the services grouped by stack.
+func (p *swarmPlugin) collectServiceMetrics(services []Service, tasks []Task) ([]*metricFamily, map[string][]Service) {
+	desired := &metricFamily{name: "swarm_service_replicas_desired", help: "Desired number of replicas of a service."}
+	running := &metricFamily{name: "swarm_service_replicas_running", help: "Number of running tasks of a service."}
+	restarts := &metricFamily{
+		name: "swarm_service_restarts",
+		help: "Number of tasks in the task history of a service that are not running.",
+	}
+	taskStates := &metricFamily{name: "swarm_service_tasks", help: "Number of tasks of a service by state."}
+	maintenance := &metricFamily{
+		name: "swarm_service_maintenance",
+		help: "Whether a service is flagged as in maintenance.",
+	}
+
+	serviceTasks := map[string][]Task{}
+	for _, task := range tasks {
+		serviceTasks[task.ServiceID] = append(serviceTasks[task.ServiceID], task)
+	}
+
+	stacks := map[string][]Service{}
+
+	for _, s := range services {
+		stackName, serviceKey := p.resolveService(s.Spec.Name, s.Spec.Labels)
+
+		stacks[stackName] = append(stacks[stackName], s)
+
+		labels := []string{"service_key", serviceKey, "service_name", s.Spec.Name, "stack", stackName}
+
+		if replicas, err := p.getServiceDesiredReplicas(s); err == nil {
+			desired.add(float64(replicas), labels...)
+		}
+
+		runningCount, restartCount := 0, 0
+		states := map[string]int{}
+
+		for _, task := range serviceTasks[s.ID] {
+			states[task.Status.State]++
+
+			// Same semantics as the replicas_running and restarts items
+			if task.Status.State != "running" {
+				restartCount++
+			} else if task.DesiredState == "running" {
+				runningCount++
+			}
+		}
+
+		running.add(float64(runningCount), labels...)
+		restarts.add(float64(restartCount), labels...)
+		maintenance.addFlag(inMaintenance(s), labels...)
+
+		for state, count := range states {
+			taskStates.add(float64(count), append(labels, "state", state)...)
+		}
+	}
+
+	return []*metricFamily{desired, running, restarts, taskStates, maintenance}, stacks
+}
+
+// collectStackMetrics returns the stack metric families, evaluated with the default health mode.
+func (p *swarmPlugin) collectStackMetrics(stacks map[string][]Service, tasks []Task) []*metricFamily {
+	status := &metricFamily{
+		name: "swarm_stack_status",
+		help: "Stack status: 0 - ok, 1 - degraded, 2 - critical, 3 - unknown.",
+	}
+	percentage := &metricFamily{
+		name: "swarm_stack_health_percent",
+		help: "Weighted percentage of healthy services of a stack.",
+	}
+	serviceStates := &metricFamily{name: "swarm_stack_services", help: "Number of services of a stack by state."}
+
+	runningTasks := map[string]int{}
+
+	for _, task := range tasks {
+		if task.DesiredState == "running" && task.Status.State == "running" {
+			runningTasks[task.ServiceID]++
+		}
+	}
+
+	running := func(serviceID string) (int, error) {
+		return runningTasks[serviceID], nil
+	}
+
+	for stackName, services := range stacks {
+		health := p.evaluateStackHealth(stackName, services, healthCriteria{percent: 100}, running)
+
+		status.add(float64(health.StatusCode), "stack", stackName)
+		percentage.add(health.HealthPercentage, "stack", stackName)
+
+		for state, count := range map[string]int{
+			serviceStateHealthy:     health.HealthyServices,
+			serviceStateDegraded:    health.DegradedServices,
+			serviceStateDown:        health.DownServices,
+			serviceStateUnknown:     health.UnknownServices,
+			serviceStateMaintenance: health.MaintenanceServices,
+		} {
+			serviceStates.add(float64(count), "stack", stackName, "state", state)
+		}
+	}
+
+	return []*metricFamily{status, percentage, serviceStates}
+}
+
+// collectNodeMetrics returns the node metric families.
+func collectNodeMetrics(nodes []Node, tasks []Task) []*metricFamily {
+	ready := &metricFamily{name: "swarm_node_ready", help: "Whether a node is ready."}
+	active := &metricFamily{name: "swarm_node_active", help: "Whether a node accepts new tasks."}
+	runningTasks := &metricFamily{
+		name: "swarm_node_tasks_running",
+		help: "Number of running tasks of monitored services on a node.",
+	}
+
+	running := map[string]int{}
+
+	for _, task := range tasks {
+		if task.DesiredState == "running" && task.Status.State == "running" {
+			running[task.NodeID]++
+		}
+	}
+
+	for _, node := range nodes {
+		labels := []string{"node_id", node.ID, "hostname", node.Description.Hostname, "role", node.Spec.Role}
+
+		ready.addFlag(node.Status.State == "ready", labels...)
+		active.addFlag(node.Spec.Availability == "active", labels...)
+		runningTasks.add(float64(running[node.ID]), labels...)
+	}
+
+	return []*metricFamily{ready, active, runningTasks}
+}
+
+func (p *swarmPlugin) getNodes() ([]Node, error) {
+	body, err := p.client.Query("nodes", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []Node
+	if err = json.Unmarshal(body, &nodes); err != nil {
+		return nil, errs.Wrap(err, "cannot unmarshal JSON")
+	}
+
+	return nodes, nil
+}
+
+// writeMetrics writes the metric families as gauges with their samples sorted by labels. The
+// OpenMetrics format is terminated by an EOF marker.
+func writeMetrics(w io.Writer, families []*metricFamily, openMetrics bool) {
+	var b strings.Builder
+
+	for _, f := range families {
+		b.WriteString("# HELP " + f.name + " " + f.help + "\n")
+		b.WriteString("# TYPE " + f.name + " gauge\n")
+
+		lines := make([]string, 0, len(f.samples))
+		for _, sample := range f.samples {
+			lines = append(lines, f.name+formatLabels(sample.labels)+" "+sample.value)
+		}
+
+		sort.Strings(lines)
+
+		for _, line := range lines {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+
+	_, _ = io.WriteString(w, b.String())
+}
+
+// formatLabels formats label pairs as {name="value",...} with the values escaped.
+func formatLabels(labels [][2]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		pairs = append(pairs, l[0]+`="`+escaper.Replace(l[1])+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeMetrics(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	req := httptest.NewRequest(http.MethodGet, metricsPath, nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+
+	rec := httptest.NewRecorder()
+	p.serveMetrics(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != openMetricsContentType {
+		t.Fatalf("serveMetrics() content type = %q, want %q", ct, openMetricsContentType)
+	}
+
+	body := rec.Body.String()
+
+	want := []string{
+		"swarm_up 1",
+		`swarm_service_replicas_desired{service_key="mystack_web",service_name="web",stack="mystack"} 3`,
+		`swarm_service_replicas_running{service_key="mystack_web",service_name="web",stack="mystack"} 2`,
+		`swarm_service_restarts{service_key="mystack_db",service_name="db",stack="mystack"} 2`,
+		`swarm_service_tasks{service_key="mystack_web",service_name="web",stack="mystack",state="failed"} 1`,
+		`swarm_service_maintenance{service_key="jobs_batch",service_name="batch",stack="jobs"} 1`,
+		`swarm_service_replicas_running{service_key="worker",service_name="worker",stack="standalone"} 1`,
+		`swarm_stack_status{stack="mystack"} 2`,
+		`swarm_stack_health_percent{stack="mystack"} 0`,
+		`swarm_stack_status{stack="jobs"} 0`,
+		`swarm_stack_services{stack="jobs",state="maintenance"} 1`,
+		`swarm_node_ready{node_id="node000001",hostname="manager1",role="manager"} 1`,
+		`swarm_node_tasks_running{node_id="node000002",hostname="worker1",role="worker"} 2`,
+		"# TYPE swarm_stack_status gauge",
+	}
+
+	for _, line := range want {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("serveMetrics() output is missing %q", line)
+		}
+	}
+
+	// Services opted out of monitoring are not exposed
+	if strings.Contains(body, "hidden") {
+		t.Errorf("serveMetrics() exposes an unmonitored service")
+	}
+
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("serveMetrics() output does not end with the EOF marker")
+	}
+}
+
+func TestServeMetricsError(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "swarm")
+	f.fail("services", http.StatusInternalServerError)
+
+	p := newTestPlugin(t, f)
+
+	rec := httptest.NewRecorder()
+	p.serveMetrics(rec, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+
+	want := "# HELP swarm_up Whether the Docker API could be queried.\n# TYPE swarm_up gauge\nswarm_up 0\n"
+	if rec.Body.String() != want {
+		t.Fatalf("serveMetrics() = %q, want %q", rec.Body.String(), want)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != textMetricsContentType {
+		t.Fatalf("serveMetrics() content type = %q, want %q", ct, textMetricsContentType)
+	}
+}
+
+func TestStartMetricsServer(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	addr, err := p.startMetricsServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("startMetricsServer() error = %v", err)
+	}
+
+	t.Cleanup(func() { _ = p.metricsServer.Close() })
+
+	resp, err := http.Get("http://" + addr.String() + metricsPath) //nolint:noctx // test request
+	if err != nil {
+		t.Fatalf("cannot get metrics: %s", err)
+	}
+
+	defer resp.Body.Close() //nolint:errcheck // test request
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || !strings.Contains(string(body), "swarm_up 1\n") {
+		t.Fatalf("metrics listener returned %q, %v", body, err)
+	}
+}
+
+func TestStopMetricsServer(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	addr, err := p.startMetricsServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("startMetricsServer() error = %v", err)
+	}
+
+	p.Stop()
+
+	if p.metricsServer != nil {
+		t.Fatalf("Stop() kept the metrics listener")
+	}
+
+	// The address is released and can be bound again when the plugin is started again
+	if _, err = p.startMetricsServer(addr.String()); err != nil {
+		t.Fatalf("startMetricsServer() after Stop() error = %v", err)
+	}
+
+	p.Stop()
+}
+
+func TestFormatLabels(t *testing.T) {
+	t.Parallel()
+
+	got := formatLabels([][2]string{{"a", `x"y\z`}, {"b", "line\nbreak"}})
+	if want := `{a="x\"y\\z",b="line\nbreak"}`; got != want {
+		t.Fatalf("formatLabels() = %s, want %s", got, want)
+	}
+}
@@ -21,6 +21,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"golang.zabbix.com/sdk/errs"
@@ -39,8 +45,48 @@ const (
 	serviceRestartCount    = swarmMetricKey("swarm.service.restarts")
 	serviceTaskCount       = swarmMetricKey("swarm.service.tasks")
 	serviceLastRestart     = swarmMetricKey("swarm.service.last_restart")
+	serviceMaintenance     = swarmMetricKey("swarm.service.maintenance")
 	stackDiscoveryMetric   = swarmMetricKey("swarm.stacks.discovery")
 	stackHealthMetric      = swarmMetricKey("swarm.stack.health")
+	stackStatusMetric      = swarmMetricKey("swarm.stack.status")
+	stackReplicasMetric    = swarmMetricKey("swarm.stack.replicas")
+	stackRestartsMetric    = swarmMetricKey("swarm.stack.restarts")
+	stackLastDeployMetric  = swarmMetricKey("swarm.stack.last_deploy")
+	networkDiscoveryMetric = swarmMetricKey("swarm.networks.discovery")
+	networkAddresses       = swarmMetricKey("swarm.network.addresses")
+	networkAddressesPUsed  = swarmMetricKey("swarm.network.addresses_pused")
+	secretDiscoveryMetric  = swarmMetricKey("swarm.secrets.discovery")
+	secretAge              = swarmMetricKey("swarm.secret.age")
+	secretUpdateAge        = swarmMetricKey("swarm.secret.update_age")
+	secretServices         = swarmMetricKey("swarm.secret.services")
+	configDiscoveryMetric  = swarmMetricKey("swarm.configs.discovery")
+	configAge              = swarmMetricKey("swarm.config.age")
+	configUpdateAge        = swarmMetricKey("swarm.config.update_age")
+	configServices         = swarmMetricKey("swarm.config.services")
+	certDiscoveryMetric    = swarmMetricKey("swarm.certificates.discovery")
+	configCertExpiry       = swarmMetricKey("swarm.config.cert_expiry")
+	portDiscoveryMetric    = swarmMetricKey("swarm.ports.discovery")
+	servicePortCheck       = swarmMetricKey("swarm.service.port_check")
+	serviceEvents          = swarmMetricKey("swarm.service.events")
+	eventsLog              = swarmMetricKey("swarm.events.log")
+	serviceLogErrors       = swarmMetricKey("swarm.service.log_errors")
+	serviceCrashLoop       = swarmMetricKey("swarm.service.crashloop")
+	serviceUptime          = swarmMetricKey("swarm.service.uptime")
+	serviceRecreated       = swarmMetricKey("swarm.service.recreated")
+
+	// monitorLabel set to false on a service excludes it from discovery and stack health.
+	monitorLabel = "zabbix.monitor"
+	// maintenanceLabel set to true on a service keeps it discovered but flags it as in maintenance.
+	maintenanceLabel = "zabbix.maintenance"
+	// weightLabel sets the weight of a service in the stack health percentage, 1 by default.
+	weightLabel = "zabbix.weight"
+	// criticalLabel set to true on a service makes the stack critical when the service is down.
+	criticalLabel = "zabbix.critical"
+
+	// Prefixes of service identifiers restricting them to the service ID, name or key.
+	identifierID   = "id"
+	identifierName = "name"
+	identifierKey  = "key"
 )
 
 var (
@@ -53,18 +99,36 @@ type swarmMetricKey string
 type swarmMetric struct {
 	metric  *metric.Metric
 	handler func(ctx context.Context, params []string) (any, error)
+	// vanishable marks numeric service items the vanished service policy applies to.
+	vanishable bool
+	// swarmOnly marks items without an equivalent in compose mode.
+	swarmOnly bool
 }
 
 type swarmPlugin struct {
 	plugin.Base
 	client  *client
+	options pluginOptions
 	metrics map[swarmMetricKey]*swarmMetric
+
+	eventCursors eventCursors
+
+	// services tracks the services that vanished between service listings.
+	services serviceTracker
+
+	// mode is whether the daemon is monitored in compose mode, as detected in auto mode.
+	mode daemonMode
+
+	// metricsServer is the OpenMetrics listener, nil if disabled.
+	metricsServer *http.Server
+	// metricsDone is closed once the metrics listener is closed.
+	metricsDone chan struct{}
 }
 
 // Launch launches the DockerSwarm plugin. Blocks until plugin execution has finished.
 func Launch() error {
 	p := &swarmPlugin{
-		client: newClient("/var/run/docker.sock", 30),
+		client: newClient(defaultSocketPath, defaultTimeout),
 	}
 
 	err := p.registerMetrics()
@@ -94,6 +158,8 @@ func (p *swarmPlugin) Start() {
 
 // Stop stops the Docker Swarm plugin. Required for plugin to match runner interface.
 func (p *swarmPlugin) Stop() {
+	p.stopMetricsServer()
+
 	p.Infof("DockerSwarm plugin stopped")
 }
 
@@ -119,6 +185,24 @@ func (p *swarmPlugin) Export(key string, rawParams []string, _ plugin.ContextPro
 }
 
 func (p *swarmPlugin) registerMetrics() error {
+	p.initMetrics()
+
+	metricSet := metric.MetricSet{}
+
+	for k, m := range p.metrics {
+		metricSet[string(k)] = m.metric
+	}
+
+	err := plugin.RegisterMetrics(p, Name, metricSet.List()...)
+	if err != nil {
+		return errs.Wrap(err, "failed to register metrics")
+	}
+
+	return nil
+}
+
+// initMetrics fills the metrics map without registering the metrics with the agent.
+func (p *swarmPlugin) initMetrics() {
 	p.metrics = map[swarmMetricKey]*swarmMetric{
 		serviceDiscoveryMetric: {
 			metric: metric.New(
@@ -134,7 +218,8 @@ func (p *swarmPlugin) registerMetrics() error {
 				nil,
 				false,
 			),
-			handler: p.getDesiredReplicas,
+			handler:    p.getDesiredReplicas,
+			vanishable: true,
 		},
 		serviceReplicasRunning: {
 			metric: metric.New(
@@ -142,7 +227,8 @@ func (p *swarmPlugin) registerMetrics() error {
 				nil,
 				false,
 			),
-			handler: p.getRunningTasks,
+			handler:    p.getRunningTasks,
+			vanishable: true,
 		},
 		serviceRestartCount: {
 			metric: metric.New(
@@ -150,7 +236,8 @@ func (p *swarmPlugin) registerMetrics() error {
 				nil,
 				false,
 			),
-			handler: p.getServiceRestarts,
+			handler:    p.getServiceRestarts,
+			vanishable: true,
 		},
 		serviceTaskCount: {
 			metric: metric.New(
@@ -158,15 +245,26 @@ func (p *swarmPlugin) registerMetrics() error {
 				nil,
 				false,
 			),
-			handler: p.getServiceTaskCount,
+			handler:    p.getServiceTaskCount,
+			vanishable: true,
 		},
 		serviceLastRestart: {
 			metric: metric.New(
-				"Returns the timestamp of the most recent running task (for restart detection).",
+				"Returns the creation timestamp of the most recent running task (for restart detection).",
+				nil,
+				false,
+			),
+			handler:    p.getServiceLastRestart,
+			vanishable: true,
+		},
+		serviceMaintenance: {
+			metric: metric.New(
+				"Returns 1 if the service is flagged as in maintenance, 0 otherwise.",
 				nil,
 				false,
 			),
-			handler: p.getServiceLastRestart,
+			handler:    p.getServiceMaintenance,
+			vanishable: true,
 		},
 		stackDiscoveryMetric: {
 			metric: metric.New(
@@ -184,83 +282,521 @@ func (p *swarmPlugin) registerMetrics() error {
 			),
 			handler: p.getStackHealth,
 		},
+		stackStatusMetric: {
+			metric: metric.New(
+				"Returns the status of a Docker Compose stack: 0 - ok, 1 - degraded, 2 - critical, 3 - unknown.",
+				nil,
+				false,
+			),
+			handler: p.getStackStatus,
+		},
+		stackReplicasMetric: {
+			metric: metric.New(
+				"Returns the desired and running replicas summed over all services of a stack.",
+				nil,
+				false,
+			),
+			handler: p.getStackReplicas,
+		},
+		stackRestartsMetric: {
+			metric: metric.New(
+				"Returns the number of task restarts summed over all services of a stack.",
+				nil,
+				false,
+			),
+			handler: p.getStackRestarts,
+		},
+		stackLastDeployMetric: {
+			metric: metric.New(
+				"Returns the timestamp of the most recent service creation or update in a stack.",
+				nil,
+				false,
+			),
+			handler: p.getStackLastDeploy,
+		},
+		networkDiscoveryMetric: {
+			metric: metric.New(
+				"Discover Docker Swarm overlay networks.",
+				nil,
+				false,
+			),
+			handler:   p.discoverNetworks,
+			swarmOnly: true,
+		},
+		networkAddresses: {
+			metric: metric.New(
+				"Returns the total, used and free IPv4 addresses of an overlay network.",
+				nil,
+				false,
+			),
+			handler:   p.getNetworkAddresses,
+			swarmOnly: true,
+		},
+		networkAddressesPUsed: {
+			metric: metric.New(
+				"Returns the percentage of used IPv4 addresses of an overlay network.",
+				nil,
+				false,
+			),
+			handler:   p.getNetworkAddressesUsedPercent,
+			swarmOnly: true,
+		},
+		secretDiscoveryMetric: {
+			metric: metric.New(
+				"Discover Docker Swarm secrets.",
+				nil,
+				false,
+			),
+			handler:   p.discoverSwarmObjects(secretKind),
+			swarmOnly: true,
+		},
+		secretAge: {
+			metric: metric.New(
+				"Returns the number of seconds since a secret was created.",
+				nil,
+				false,
+			),
+			handler:   p.getSwarmObjectAge(secretKind, false),
+			swarmOnly: true,
+		},
+		secretUpdateAge: {
+			metric: metric.New(
+				"Returns the number of seconds since a secret was last updated.",
+				nil,
+				false,
+			),
+			handler:   p.getSwarmObjectAge(secretKind, true),
+			swarmOnly: true,
+		},
+		secretServices: {
+			metric: metric.New(
+				"Returns the number of services referencing a secret.",
+				nil,
+				false,
+			),
+			handler:   p.getSwarmObjectServices(secretKind),
+			swarmOnly: true,
+		},
+		configDiscoveryMetric: {
+			metric: metric.New(
+				"Discover Docker Swarm configs.",
+				nil,
+				false,
+			),
+			handler:   p.discoverSwarmObjects(configKind),
+			swarmOnly: true,
+		},
+		configAge: {
+			metric: metric.New(
+				"Returns the number of seconds since a config was created.",
+				nil,
+				false,
+			),
+			handler:   p.getSwarmObjectAge(configKind, false),
+			swarmOnly: true,
+		},
+		configUpdateAge: {
+			metric: metric.New(
+				"Returns the number of seconds since a config was last updated.",
+				nil,
+				false,
+			),
+			handler:   p.getSwarmObjectAge(configKind, true),
+			swarmOnly: true,
+		},
+		configServices: {
+			metric: metric.New(
+				"Returns the number of services referencing a config.",
+				nil,
+				false,
+			),
+			handler:   p.getSwarmObjectServices(configKind),
+			swarmOnly: true,
+		},
+		certDiscoveryMetric: {
+			metric: metric.New(
+				"Discover Docker Swarm configs containing PEM certificates.",
+				nil,
+				false,
+			),
+			handler:   p.discoverCertificates,
+			swarmOnly: true,
+		},
+		configCertExpiry: {
+			metric: metric.New(
+				"Returns the earliest certificate expiry timestamp of a config.",
+				nil,
+				false,
+			),
+			handler:   p.getCertificateExpiry,
+			swarmOnly: true,
+		},
+		portDiscoveryMetric: {
+			metric: metric.New(
+				"Discover ports published by Docker Swarm services.",
+				nil,
+				false,
+			),
+			handler:   p.discoverPorts,
+			swarmOnly: true,
+		},
+		servicePortCheck: {
+			metric: metric.New(
+				"Checks a TCP port published by a service on the local node: 1 - reachable, 0 - unreachable.",
+				nil,
+				false,
+			),
+			handler:   p.getServicePortCheck,
+			swarmOnly: true,
+		},
+		serviceEvents: {
+			metric: metric.New(
+				"Returns the number and the most recent service and container events of a service within a time window.",
+				nil,
+				false,
+			),
+			handler: p.getServiceEvents,
+		},
+		eventsLog: {
+			metric: metric.New(
+				"Returns the swarm events since the previous poll, one line per event.",
+				nil,
+				false,
+			),
+			handler: p.getEventsLog,
+		},
+		serviceLogErrors: {
+			metric: metric.New(
+				"Returns the number of log lines of a service within a time window matching a regular expression.",
+				nil,
+				false,
+			),
+			handler:   p.getServiceLogErrors,
+			swarmOnly: true,
+		},
+		serviceCrashLoop: {
+			metric: metric.New(
+				"Returns whether a service had repeated task failures within a time window and its backoff state.",
+				nil,
+				false,
+			),
+			handler: p.getServiceCrashLoop,
+		},
+		serviceUptime: {
+			metric: metric.New(
+				"Returns the minimum, average and maximum age of the running tasks of a service.",
+				nil,
+				false,
+			),
+			handler: p.getServiceUptime,
+		},
+		serviceRecreated: {
+			metric: metric.New(
+				"Returns the number and the time of the last re-creations of a service under the same key.",
+				nil,
+				false,
+			),
+			handler: p.getServiceRecreated,
+		},
 	}
 
-	metricSet := metric.MetricSet{}
+	for key, m := range p.metrics {
+		if m.vanishable {
+			m.handler = p.withVanishedPolicy(key, m.handler)
+		}
 
-	for k, m := range p.metrics {
-		metricSet[string(k)] = m.metric
+		if m.swarmOnly {
+			m.handler = p.withSwarmOnly(key, m.handler)
+		}
 	}
+}
 
-	err := plugin.RegisterMetrics(p, Name, metricSet.List()...)
+// getServices returns the services matching the Docker API service filters. In compose mode the
+// services are emulated from the containers of the Compose projects.
+func (p *swarmPlugin) getServices(filters map[string][]string) ([]Service, error) {
+	compose, err := p.inComposeMode()
 	if err != nil {
-		return errs.Wrap(err, "failed to register metrics")
+		return nil, err
 	}
 
-	return nil
+	var services []Service
+
+	if compose {
+		services, err = p.getComposeServices(filters)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		body, qErr := p.client.Query("services", filters)
+		if qErr != nil {
+			return nil, qErr
+		}
+
+		if err = json.Unmarshal(body, &services); err != nil {
+			return nil, errs.Wrap(err, "cannot unmarshal JSON")
+		}
+	}
+
+	// Only complete listings tell which services were removed
+	if len(filters) == 0 {
+		tracked := make([]trackedService, 0, len(services))
+		for _, s := range services {
+			tracked = append(tracked, p.trackService(s))
+		}
+
+		p.services.observe(tracked, p.vanishedGracePeriod(), time.Now())
+	}
+
+	return services, nil
 }
 
-func (p *swarmPlugin) getServices() ([]Service, error) {
-	body, err := p.client.Query("services", nil)
+// getTasks returns the tasks matching the Docker API task filters. In compose mode the tasks are
+// emulated from the containers of the Compose projects.
+func (p *swarmPlugin) getTasks(filters map[string][]string) ([]Task, error) {
+	compose, err := p.inComposeMode()
 	if err != nil {
 		return nil, err
 	}
 
-	var services []Service
-	if err = json.Unmarshal(body, &services); err != nil {
+	if compose {
+		return p.getComposeTasks(filters)
+	}
+
+	body, err := p.client.Query("tasks", filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []Task
+	if err = json.Unmarshal(body, &tasks); err != nil {
 		return nil, errs.Wrap(err, "cannot unmarshal JSON")
 	}
 
-	return services, nil
+	return tasks, nil
 }
 
-func (p *swarmPlugin) discoverServices(_ context.Context, params []string) (any, error) {
-	if len(params) != 0 {
-		return nil, errs.New("expected no parameters for service discovery")
+// discoveryFilter selects the services returned by service discovery.
+type discoveryFilter struct {
+	nameInclude  *regexp.Regexp
+	nameExclude  *regexp.Regexp
+	stackInclude *regexp.Regexp
+	stackExclude *regexp.Regexp
+	labels       []string
+}
+
+// newDiscoveryFilter parses the optional service discovery parameters:
+// <name include>,<name exclude>,<stack include>,<stack exclude>,<label filter>...
+// Label filters use the Docker "label" filter syntax ("key" or "key=value").
+func newDiscoveryFilter(params []string) (*discoveryFilter, error) {
+	f := &discoveryFilter{}
+
+	patterns := []**regexp.Regexp{&f.nameInclude, &f.nameExclude, &f.stackInclude, &f.stackExclude}
+	for i, re := range patterns {
+		if i >= len(params) || params[i] == "" {
+			continue
+		}
+
+		compiled, err := regexp.Compile(params[i])
+		if err != nil {
+			return nil, errs.Wrap(err, "invalid regular expression "+params[i])
+		}
+
+		*re = compiled
+	}
+
+	if len(params) > len(patterns) {
+		for _, label := range params[len(patterns):] {
+			if label != "" {
+				f.labels = append(f.labels, label)
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// queryFilters returns the Docker API filters for the label filters, nil if there are none.
+func (f *discoveryFilter) queryFilters() map[string][]string {
+	if len(f.labels) == 0 {
+		return nil
+	}
+
+	return map[string][]string{"label": f.labels}
+}
+
+// match reports whether a service with the given name and stack passes the regular expressions.
+func (f *discoveryFilter) match(name, stackName string) bool {
+	if f.nameInclude != nil && !f.nameInclude.MatchString(name) {
+		return false
+	}
+
+	if f.nameExclude != nil && f.nameExclude.MatchString(name) {
+		return false
 	}
 
-	services, err := p.getServices()
+	if f.stackInclude != nil && !f.stackInclude.MatchString(stackName) {
+		return false
+	}
+
+	if f.stackExclude != nil && f.stackExclude.MatchString(stackName) {
+		return false
+	}
+
+	return true
+}
+
+func (p *swarmPlugin) discoverServices(_ context.Context, params []string) (any, error) {
+	filter, err := newDiscoveryFilter(params)
 	if err != nil {
 		return nil, err
 	}
 
-	type LLDService struct {
-		ID        string `json:"{#SERVICE.ID}"`
-		Name      string `json:"{#SERVICE.NAME}"`
-		StackName string `json:"{#STACK.NAME}"`
-		// Add service name as primary identifier for stable monitoring
-		ServiceKey string `json:"{#SERVICE.KEY}"` // This will be the stable identifier
+	services, err := p.getServices(filter.queryFilters())
+	if err != nil {
+		return nil, err
 	}
 
-	lldServices := make([]LLDService, 0, len(services))
+	patterns := p.options.labelPatterns()
+
+	lldServices := make([]map[string]string, 0, len(services))
 	for _, s := range services {
-		stackName := "standalone"
-		if s.Spec.Labels != nil {
-			if namespace, exists := s.Spec.Labels["com.docker.stack.namespace"]; exists {
-				stackName = namespace
+		// The service key is stackname_servicename or just servicename for standalone services
+		stackName, serviceKey := p.resolveService(s.Spec.Name, s.Spec.Labels)
+
+		if !isMonitored(s) || !filter.match(s.Spec.Name, stackName) {
+			continue
+		}
+
+		lldService := map[string]string{
+			"{#SERVICE.ID}":   s.ID,
+			"{#SERVICE.NAME}": s.Spec.Name,
+			"{#STACK.NAME}":   stackName,
+			// Service key is the stable identifier for monitoring
+			"{#SERVICE.KEY}":         serviceKey,
+			"{#SERVICE.MAINTENANCE}": boolToFlag(inMaintenance(s)),
+		}
+
+		p.addLabelMacros(lldService, s, patterns)
+
+		lldServices = append(lldServices, lldService)
+	}
+
+	return jsonResult(lldServices)
+}
+
+// isMonitored reports whether a service has not opted out of monitoring with the monitor label.
+func isMonitored(s Service) bool {
+	value, ok := s.Spec.Labels[monitorLabel]
+	if !ok {
+		return true
+	}
+
+	monitored, err := strconv.ParseBool(value)
+
+	return err != nil || monitored
+}
+
+// inMaintenance reports whether a service is flagged as in maintenance with the maintenance label.
+func inMaintenance(s Service) bool {
+	maintenance, err := strconv.ParseBool(s.Spec.Labels[maintenanceLabel])
+
+	return err == nil && maintenance
+}
+
+// isCritical reports whether a service is flagged as critical for its stack with the critical label.
+func isCritical(s Service) bool {
+	critical, err := strconv.ParseBool(s.Spec.Labels[criticalLabel])
+
+	return err == nil && critical
+}
+
+// serviceWeight returns the weight of a service from the weight label, 1 if unset or invalid.
+func serviceWeight(s Service) float64 {
+	weight, err := strconv.ParseFloat(s.Spec.Labels[weightLabel], 64)
+	if err != nil || weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+		return 1
+	}
+
+	return weight
+}
+
+func boolToFlag(b bool) string {
+	if b {
+		return "1"
+	}
+
+	return "0"
+}
+
+// matchLabel reports whether a label name matches one of the patterns.
+// A pattern ending in "*" matches by prefix, an empty pattern list matches everything.
+func matchLabel(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
 			}
+
+			continue
 		}
 
-		// Create stable service key: stackname_servicename or just servicename for standalone
-		serviceKey := s.Spec.Name
-		if stackName != "standalone" {
-			serviceKey = stackName + "_" + s.Spec.Name
+		if name == pattern {
+			return true
 		}
+	}
+
+	return false
+}
+
+// addLabelMacros adds the labels of a service matching the patterns to its LLD macros. Labels are
+// added in name order, so when several labels convert to the same macro the first name wins.
+func (p *swarmPlugin) addLabelMacros(macros map[string]string, s Service, patterns []string) {
+	names := make([]string, 0, len(s.Spec.Labels))
 
-		lldServices = append(lldServices, LLDService{
-			ID:         s.ID,
-			Name:       s.Spec.Name,
-			StackName:  stackName,
-			ServiceKey: serviceKey,
-		})
+	for name := range s.Spec.Labels {
+		if matchLabel(name, patterns) {
+			names = append(names, name)
+		}
 	}
 
-	jsonData, err := json.Marshal(lldServices)
-	if err != nil {
-		return nil, errs.Wrap(err, "cannot marshal JSON")
+	sort.Strings(names)
+
+	labels := make(map[string]string, len(names))
+
+	for _, name := range names {
+		macro := labelMacro(name)
+
+		if first, exists := labels[macro]; exists {
+			p.Warningf("service %s: label %s is skipped, its macro %s is already set by label %s",
+				s.Spec.Name, name, macro, first)
+
+			continue
+		}
+
+		labels[macro] = name
+		macros[macro] = s.Spec.Labels[name]
 	}
+}
 
-	return string(jsonData), nil
+// labelMacro converts a label name into an LLD macro name, e.g. "zabbix.team" becomes
+// {#SERVICE.LABEL.ZABBIX.TEAM}. Characters not allowed in macro names are replaced with "_".
+func labelMacro(name string) string {
+	macro := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+
+	return "{#SERVICE.LABEL." + macro + "}"
 }
 
 func (p *swarmPlugin) discoverStacks(_ context.Context, params []string) (any, error) {
@@ -268,24 +804,33 @@ func (p *swarmPlugin) discoverStacks(_ context.Context, params []string) (any, e
 		return nil, errs.New("expected no parameters for stack discovery")
 	}
 
-	services, err := p.getServices()
+	services, err := p.getServices(nil)
 	if err != nil {
 		return nil, err
 	}
 
+	// stacksMap tells for each stack name whether it comes from a stack label, standalone services
+	// are listed under the pseudo-stack name
 	stacksMap := make(map[string]bool)
+	standaloneName := p.options.standaloneStackName()
+	hasStandalone := false
+
 	for _, s := range services {
-		stackName := "standalone"
-		if s.Spec.Labels != nil {
-			if namespace, exists := s.Spec.Labels["com.docker.stack.namespace"]; exists {
-				stackName = namespace
-			}
+		if !isMonitored(s) {
+			continue
+		}
+
+		stackName, stacked := p.resolveStack(s.Spec.Labels)
+		if !stacked {
+			hasStandalone = true
 		}
-		stacksMap[stackName] = true
+
+		stacksMap[stackName] = stacksMap[stackName] || stacked
 	}
 
-	type LLDStack struct {
-		StackName string `json:"{#STACK.NAME}"`
+	if hasStandalone && stacksMap[standaloneName] {
+		p.Warningf("stack %s has the name of the standalone services pseudo-stack, "+
+			"set StandaloneStackName to tell them apart", standaloneName)
 	}
 
 	lldStacks := make([]LLDStack, 0, len(stacksMap))
@@ -293,79 +838,255 @@ func (p *swarmPlugin) discoverStacks(_ context.Context, params []string) (any, e
 		lldStacks = append(lldStacks, LLDStack{StackName: stackName})
 	}
 
-	jsonData, err := json.Marshal(lldStacks)
+	return jsonResult(lldStacks)
+}
+
+// Service health states reported in the stack health breakdown.
+const (
+	serviceStateHealthy     = "healthy"
+	serviceStateDegraded    = "degraded"
+	serviceStateDown        = "down"
+	serviceStateUnknown     = "unknown"
+	serviceStateMaintenance = "maintenance"
+)
+
+// healthCriteria defines when a service counts as healthy.
+type healthCriteria struct {
+	// any makes a service healthy with at least one running replica.
+	any bool
+	// percent is the minimum percentage of desired replicas that must be running.
+	percent float64
+}
+
+// newHealthCriteria parses the stack health mode parameter: "all" (default), "any" or a
+// percentage of desired replicas such as "80" or "80%".
+func newHealthCriteria(mode string) (healthCriteria, error) {
+	switch mode {
+	case "", "all":
+		return healthCriteria{percent: 100}, nil
+	case "any":
+		return healthCriteria{any: true}, nil
+	}
+
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(mode, "%"), 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return healthCriteria{}, errs.New("invalid health mode " + mode + ", expected all, any or 0-100")
+	}
+
+	return healthCriteria{percent: percent}, nil
+}
+
+// state returns the health state of a service with the given replica counts.
+func (c healthCriteria) state(desired, running int) string {
+	healthy := float64(running)*100 >= c.percent*float64(desired)
+	if c.any {
+		healthy = running > 0 || desired == 0
+	}
+
+	switch {
+	case healthy:
+		return serviceStateHealthy
+	case running == 0:
+		return serviceStateDown
+	default:
+		return serviceStateDegraded
+	}
+}
+
+// Stack status values returned by the stack status metric.
+const (
+	stackStatusOK       = 0
+	stackStatusDegraded = 1
+	stackStatusCritical = 2
+	stackStatusUnknown  = 3
+)
+
+// stackStatusNames maps stack status values to the names reported in the stack health.
+var stackStatusNames = map[int]string{
+	stackStatusOK:       "ok",
+	stackStatusDegraded: "degraded",
+	stackStatusCritical: "critical",
+	stackStatusUnknown:  "unknown",
+}
+
+// parseStackParams parses the <stack>,<mode> parameters shared by the stack health metrics.
+func parseStackParams(params []string, metricName string) (string, healthCriteria, error) {
+	if len(params) < 1 || len(params) > 2 {
+		return "", healthCriteria{}, errs.New("expected 1 or 2 parameters for " + metricName)
+	}
+
+	var mode string
+	if len(params) > 1 {
+		mode = params[1]
+	}
+
+	criteria, err := newHealthCriteria(mode)
 	if err != nil {
-		return nil, errs.Wrap(err, "cannot marshal JSON")
+		return "", healthCriteria{}, err
 	}
 
-	return string(jsonData), nil
+	return params[0], criteria, nil
 }
 
 func (p *swarmPlugin) getStackHealth(_ context.Context, params []string) (any, error) {
-	if len(params) != 1 {
-		return nil, errs.New("expected 1 parameter for stack health")
+	stackName, criteria, err := parseStackParams(params, "stack health")
+	if err != nil {
+		return nil, err
 	}
 
-	stackName := params[0]
-	services, err := p.getServices()
+	health, err := p.stackHealth(stackName, criteria)
 	if err != nil {
 		return nil, err
 	}
 
-	// Filter services for this stack
-	var stackServices []Service
-	for _, s := range services {
-		serviceStackName := "standalone"
-		if s.Spec.Labels != nil {
-			if namespace, exists := s.Spec.Labels["com.docker.stack.namespace"]; exists {
-				serviceStackName = namespace
-			}
-		}
-		if serviceStackName == stackName {
-			stackServices = append(stackServices, s)
-		}
+	return jsonResult(health)
+}
+
+func (p *swarmPlugin) getStackStatus(_ context.Context, params []string) (any, error) {
+	stackName, criteria, err := parseStackParams(params, "stack status")
+	if err != nil {
+		return nil, err
 	}
 
-	if len(stackServices) == 0 {
-		return nil, errs.New("stack not found: " + stackName)
+	health, err := p.stackHealth(stackName, criteria)
+	if err != nil {
+		return nil, err
 	}
 
-	totalServices := len(stackServices)
-	healthyServices := 0
+	return health.StatusCode, nil
+}
+
+// stackHealth evaluates all monitored services of a stack. The health percentage is weighted
+// by the weight label of the services and a critical service that is down makes the stack critical.
+func (p *swarmPlugin) stackHealth(stackName string, criteria healthCriteria) (*StackHealth, error) {
+	stackServices, err := p.getStackServices(stackName)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.evaluateStackHealth(stackName, stackServices, criteria, p.getServiceRunningTasks), nil
+}
+
+// evaluateStackHealth evaluates the given services of a stack, with running returning the number
+// of running tasks of a service.
+func (p *swarmPlugin) evaluateStackHealth(
+	stackName string, stackServices []Service, criteria healthCriteria, running func(serviceID string) (int, error),
+) *StackHealth {
+	health := &StackHealth{
+		StackName:     stackName,
+		TotalServices: len(stackServices),
+		Services:      make([]ServiceHealth, 0, len(stackServices)),
+	}
+
+	var healthyWeight, evaluatedWeight float64
+
+	criticalDown := false
 
 	// Check health of each service
 	for _, service := range stackServices {
-		desired, dErr := p.getServiceDesiredReplicas(service)
-		if dErr != nil {
-			continue // Skip services we can't evaluate
+		serviceHealth := p.evaluateServiceHealth(service, stackName, criteria, running)
+
+		switch serviceHealth.State {
+		case serviceStateHealthy:
+			health.HealthyServices++
+			healthyWeight += serviceHealth.Weight
+			evaluatedWeight += serviceHealth.Weight
+		case serviceStateDegraded:
+			health.DegradedServices++
+			evaluatedWeight += serviceHealth.Weight
+		case serviceStateDown:
+			health.DownServices++
+			evaluatedWeight += serviceHealth.Weight
+			criticalDown = criticalDown || serviceHealth.Critical
+		case serviceStateMaintenance:
+			health.MaintenanceServices++
+		default:
+			health.UnknownServices++
 		}
 
-		running, rErr := p.getServiceRunningTasks(service.ID)
-		if rErr != nil {
-			continue // Skip services we can't evaluate
-		}
+		health.Services = append(health.Services, serviceHealth)
+	}
 
-		if running >= desired {
-			healthyServices++
-		}
+	health.UnhealthyServices = health.DegradedServices + health.DownServices
+
+	evaluated := health.HealthyServices + health.UnhealthyServices
+	switch {
+	case criticalDown:
+		health.HealthPercentage = 0
+	case evaluatedWeight > 0:
+		health.HealthPercentage = healthyWeight / evaluatedWeight * 100
+	case evaluated > 0:
+		// Only services with weight 0 were evaluated
+		health.HealthPercentage = float64(health.HealthyServices) / float64(evaluated) * 100
+	case health.UnknownServices == 0:
+		// Only services in maintenance
+		health.HealthPercentage = 100
+	}
+
+	switch {
+	case criticalDown:
+		health.StatusCode = stackStatusCritical
+	case evaluated == 0 && health.UnknownServices > 0:
+		health.StatusCode = stackStatusUnknown
+	case health.UnhealthyServices == 0:
+		health.StatusCode = stackStatusOK
+	case health.HealthyServices == 0:
+		health.StatusCode = stackStatusCritical
+	default:
+		health.StatusCode = stackStatusDegraded
 	}
 
-	unhealthyServices := totalServices - healthyServices
-	healthPercentage := float64(healthyServices) / float64(totalServices) * 100
+	health.Status = stackStatusNames[health.StatusCode]
+
+	return health
+}
 
-	result := map[string]interface{}{
-		"total_services":     totalServices,
-		"healthy_services":   healthyServices,
-		"unhealthy_services": unhealthyServices,
-		"health_percentage":  healthPercentage,
+// evaluateServiceHealth compares the desired and running replicas of a stack service.
+// Services that cannot be evaluated are reported with the unknown state and the error as reason.
+func (p *swarmPlugin) evaluateServiceHealth(
+	service Service, stackName string, criteria healthCriteria, running func(serviceID string) (int, error),
+) ServiceHealth {
+	_, serviceKey := p.resolveService(service.Spec.Name, service.Spec.Labels)
+
+	serviceHealth := ServiceHealth{
+		Name:     service.Spec.Name,
+		Key:      serviceKey,
+		Weight:   serviceWeight(service),
+		Critical: isCritical(service),
 	}
 
-	jsonData, err := json.Marshal(result)
+	// Services in maintenance do not affect the stack health
+	if inMaintenance(service) {
+		serviceHealth.State = serviceStateMaintenance
+
+		return serviceHealth
+	}
+
+	desired, err := p.getServiceDesiredReplicas(service)
 	if err != nil {
-		return nil, errs.Wrap(err, "cannot marshal JSON")
+		serviceHealth.State = serviceStateUnknown
+		serviceHealth.Reason = err.Error()
+
+		return serviceHealth
 	}
 
-	return string(jsonData), nil
+	runningTasks, err := running(service.ID)
+	if err != nil {
+		serviceHealth.State = serviceStateUnknown
+		serviceHealth.Reason = err.Error()
+
+		return serviceHealth
+	}
+
+	serviceHealth.Desired = desired
+	serviceHealth.Running = runningTasks
+	serviceHealth.State = criteria.state(desired, runningTasks)
+
+	if serviceHealth.State != serviceStateHealthy {
+		serviceHealth.Reason = strconv.Itoa(runningTasks) + " of " + strconv.Itoa(desired) + " replicas running"
+	}
+
+	return serviceHealth
 }
 
 func (p *swarmPlugin) getDesiredReplicas(_ context.Context, params []string) (any, error) {
@@ -378,7 +1099,7 @@ func (p *swarmPlugin) getDesiredReplicas(_ context.Context, params []string) (an
 	// Find the service by identifier (ID, name, or service key)
 	service, err := p.findServiceByIdentifier(serviceIdentifier)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	return p.getServiceDesiredReplicas(*service)
@@ -410,7 +1131,7 @@ func (p *swarmPlugin) getRunningTasks(_ context.Context, params []string) (any,
 	// Find the service by identifier (ID, name, or service key)
 	service, err := p.findServiceByIdentifier(serviceIdentifier)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	return p.getServiceRunningTasks(service.ID)
@@ -422,16 +1143,11 @@ func (p *swarmPlugin) getServiceRunningTasks(serviceID string) (int, error) {
 		"desired-state": {"running"},
 	}
 
-	body, err := p.client.Query("tasks", filters)
+	tasks, err := p.getTasks(filters)
 	if err != nil {
 		return 0, err
 	}
 
-	var tasks []Task
-	if err = json.Unmarshal(body, &tasks); err != nil {
-		return 0, errs.Wrap(err, "cannot unmarshal JSON")
-	}
-
 	count := 0
 	for _, task := range tasks {
 		if task.Status.State == "running" {
@@ -442,41 +1158,68 @@ func (p *swarmPlugin) getServiceRunningTasks(serviceID string) (int, error) {
 	return count, nil
 }
 
-// findServiceByIdentifier finds a service by ID, name, or service key
+// findServiceByIdentifier finds a service by ID, name, or service key. The id:, name: and key:
+// prefixes restrict the identifier to one kind. An identifier matching no service exactly is
+// matched as an ID prefix, like the Docker CLI does. An identifier matching several services is
+// ambiguous and returns an error rather than an arbitrary one of them.
 func (p *swarmPlugin) findServiceByIdentifier(identifier string) (*Service, error) {
-	services, err := p.getServices()
+	kind, value := parseServiceIdentifier(identifier)
+	if value == "" {
+		return nil, errs.New("empty service identifier")
+	}
+
+	services, err := p.getServices(nil)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, s := range services {
-		// Check if it's a service ID
-		if s.ID == identifier {
-			return &s, nil
-		}
+	var matches []Service
 
-		// Check if it's a service name
-		if s.Spec.Name == identifier {
-			return &s, nil
+	// Identifiers without a prefix also match as a prefix of the service ID like the Docker CLI
+	// does, so an ID prefix that is the name or key of another service is ambiguous
+	for _, s := range services {
+		tracked := p.trackService(s)
+		if tracked.matches(identifier) || (kind == "" && strings.HasPrefix(s.ID, value)) {
+			matches = append(matches, s)
 		}
+	}
 
-		// Check if it's a service key (stackname_servicename)
-		stackName := "standalone"
-		if s.Spec.Labels != nil {
-			if namespace, exists := s.Spec.Labels["com.docker.stack.namespace"]; exists {
-				stackName = namespace
+	if len(matches) == 0 && kind == identifierID {
+		for _, s := range services {
+			if strings.HasPrefix(s.ID, value) {
+				matches = append(matches, s)
 			}
 		}
-		serviceKey := s.Spec.Name
-		if stackName != "standalone" {
-			serviceKey = stackName + "_" + s.Spec.Name
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, newNotFoundError("service", identifier)
+	case 1:
+		return &matches[0], nil
+	default:
+		candidates := make([]string, 0, len(matches))
+		for _, s := range matches {
+			tracked := p.trackService(s)
+			candidates = append(candidates, tracked.id+" (key "+tracked.key+")")
 		}
-		if serviceKey == identifier {
-			return &s, nil
+
+		return nil, errs.New("ambiguous service identifier " + identifier + " matches services " +
+			strings.Join(candidates, ", ") + ", use an id:, name: or key: prefix")
+	}
+}
+
+// parseServiceIdentifier splits a service identifier into its id, name or key prefix and value.
+// The prefix is empty for identifiers without one. Service names can not contain a colon.
+func parseServiceIdentifier(identifier string) (string, string) {
+	if kind, value, found := strings.Cut(identifier, ":"); found {
+		switch kind {
+		case identifierID, identifierName, identifierKey:
+			return kind, value
 		}
 	}
 
-	return nil, errs.New("service not found: " + identifier)
+	return "", identifier
 }
 
 func (p *swarmPlugin) getServiceRestarts(_ context.Context, params []string) (any, error) {
@@ -489,7 +1232,7 @@ func (p *swarmPlugin) getServiceRestarts(_ context.Context, params []string) (an
 	// Find the service by identifier (ID, name, or service key)
 	targetService, err := p.findServiceByIdentifier(serviceIdentifier)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	// Get all tasks for the service (not just running ones)
@@ -497,14 +1240,9 @@ func (p *swarmPlugin) getServiceRestarts(_ context.Context, params []string) (an
 		"service": {targetService.ID},
 	}
 
-	body, err := p.client.Query("tasks", filters)
+	tasks, err := p.getTasks(filters)
 	if err != nil {
-		return 0, err
-	}
-
-	var tasks []Task
-	if err = json.Unmarshal(body, &tasks); err != nil {
-		return 0, errs.Wrap(err, "cannot unmarshal JSON")
+		return nil, err
 	}
 
 	// Count restarts by looking at task creation timestamps
@@ -527,6 +1265,23 @@ func (p *swarmPlugin) getServiceRestarts(_ context.Context, params []string) (an
 	return restartCount, nil
 }
 
+func (p *swarmPlugin) getServiceMaintenance(_ context.Context, params []string) (any, error) {
+	if len(params) != 1 {
+		return nil, errs.New("expected 1 parameter for service maintenance")
+	}
+
+	targetService, err := p.findServiceByIdentifier(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if inMaintenance(*targetService) {
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
 func (p *swarmPlugin) getServiceTaskCount(_ context.Context, params []string) (any, error) {
 	if len(params) != 1 {
 		return nil, errs.New("expected 1 parameter for service task count")
@@ -537,7 +1292,7 @@ func (p *swarmPlugin) getServiceTaskCount(_ context.Context, params []string) (a
 	// Find the service by identifier (ID, name, or service key)
 	targetService, err := p.findServiceByIdentifier(serviceIdentifier)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	// Get all tasks for the service (not just running ones)
@@ -545,14 +1300,9 @@ func (p *swarmPlugin) getServiceTaskCount(_ context.Context, params []string) (a
 		"service": {targetService.ID},
 	}
 
-	body, err := p.client.Query("tasks", filters)
+	tasks, err := p.getTasks(filters)
 	if err != nil {
-		return 0, err
-	}
-
-	var tasks []Task
-	if err = json.Unmarshal(body, &tasks); err != nil {
-		return 0, errs.Wrap(err, "cannot unmarshal JSON")
+		return nil, err
 	}
 
 	// Return total task count for debugging
@@ -569,7 +1319,7 @@ func (p *swarmPlugin) getServiceLastRestart(_ context.Context, params []string)
 	// Find the service by identifier (ID, name, or service key)
 	targetService, err := p.findServiceByIdentifier(serviceIdentifier)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	// Get all tasks for the service (not just running ones)
@@ -577,26 +1327,18 @@ func (p *swarmPlugin) getServiceLastRestart(_ context.Context, params []string)
 		"service": {targetService.ID},
 	}
 
-	body, err := p.client.Query("tasks", filters)
+	tasks, err := p.getTasks(filters)
 	if err != nil {
-		return 0, err
-	}
-
-	var tasks []Task
-	if err = json.Unmarshal(body, &tasks); err != nil {
-		return 0, errs.Wrap(err, "cannot unmarshal JSON")
+		return nil, err
 	}
 
-	// Find the most recent running task and return its timestamp
+	// Find the most recently started running task and return its start time
 	var mostRecentTimestamp int64 = 0
 
 	for _, task := range tasks {
-		if task.Status.State == "running" && task.Status.Timestamp != "" {
-			// Parse the timestamp (Docker uses RFC3339 format)
-			if timestamp, err := time.Parse(time.RFC3339, task.Status.Timestamp); err == nil {
-				if timestamp.Unix() > mostRecentTimestamp {
-					mostRecentTimestamp = timestamp.Unix()
-				}
+		if task.Status.State == "running" {
+			if timestamp, ok := taskStartTime(task); ok && timestamp.Unix() > mostRecentTimestamp {
+				mostRecentTimestamp = timestamp.Unix()
 			}
 		}
 	}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiscoverServices(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		params  []string
+		want    []string
+		wantErr bool
+	}{
+		{"all", nil, []string{"batch", "db", "web", "worker"}, false},
+		{"stackInclude", []string{"", "", "^mystack$"}, []string{"db", "web"}, false},
+		{"stackExclude", []string{"", "", "", "^mystack$"}, []string{"batch", "worker"}, false},
+		{"nameInclude", []string{"^w"}, []string{"web", "worker"}, false},
+		{"nameExclude", []string{"", "^w"}, []string{"batch", "db"}, false},
+		{"labelFilter", []string{"", "", "", "", "zabbix.team=payments"}, []string{"web"}, false},
+		{"labelFilterAnd", []string{"", "", "", "", "zabbix.team", "zabbix.critical"}, []string{}, false},
+		{"invalidRegexp", []string{"("}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+			res, err := p.discoverServices(context.Background(), tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("discoverServices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			var lld []map[string]string
+			if err = decodeResult(res, &lld); err != nil {
+				t.Fatalf("cannot decode result: %s", err)
+			}
+
+			names := make([]string, 0, len(lld))
+			for _, s := range lld {
+				names = append(names, s["{#SERVICE.NAME}"])
+			}
+
+			sort.Strings(names)
+
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("discoverServices() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverServicesMacros(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+	p.options.LabelMacros = "zabbix.*"
+
+	res, err := p.discoverServices(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("discoverServices() error = %v", err)
+	}
+
+	var lld []map[string]string
+	if err = decodeResult(res, &lld); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	byName := map[string]map[string]string{}
+	for _, s := range lld {
+		byName[s["{#SERVICE.NAME}"]] = s
+	}
+
+	want := map[string]string{
+		"{#SERVICE.ID}":                  svcWebID,
+		"{#SERVICE.NAME}":                "web",
+		"{#STACK.NAME}":                  "mystack",
+		"{#SERVICE.KEY}":                 "mystack_web",
+		"{#SERVICE.MAINTENANCE}":         "0",
+		"{#SERVICE.LABEL.ZABBIX.TEAM}":   "payments",
+		"{#SERVICE.LABEL.ZABBIX.WEIGHT}": "2",
+	}
+
+	for macro, value := range want {
+		if byName["web"][macro] != value {
+			t.Errorf("web %s = %q, want %q", macro, byName["web"][macro], value)
+		}
+	}
+
+	if _, ok := byName["web"]["{#SERVICE.LABEL.COM.DOCKER.STACK.NAMESPACE}"]; ok {
+		t.Errorf("label not matching LabelMacros exposed")
+	}
+
+	if byName["worker"]["{#STACK.NAME}"] != "standalone" || byName["worker"]["{#SERVICE.KEY}"] != "worker" {
+		t.Errorf("worker stack/key = %q/%q, want standalone/worker",
+			byName["worker"]["{#STACK.NAME}"], byName["worker"]["{#SERVICE.KEY}"])
+	}
+
+	if byName["batch"]["{#SERVICE.MAINTENANCE}"] != "1" {
+		t.Errorf("batch maintenance = %q, want 1", byName["batch"]["{#SERVICE.MAINTENANCE}"])
+	}
+}
+
+func TestDiscoverServicesMacroCollision(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "swarm")
+	p := newTestPlugin(t, f)
+
+	// Both labels convert to {#SERVICE.LABEL.ZABBIX.ON_CALL}
+	f.addService(`{
+		"ID": "svcpager01",
+		"Spec": {
+			"Name": "pager",
+			"Labels": {"zabbix.on_call": "second", "zabbix.on-call": "first"},
+			"Mode": {"Replicated": {"Replicas": 1}}
+		}
+	}`)
+
+	// The first label name wins whatever the map iteration order
+	for range 10 {
+		res, err := p.discoverServices(context.Background(), []string{"^pager$"})
+		if err != nil {
+			t.Fatalf("discoverServices() error = %v", err)
+		}
+
+		var lld []map[string]string
+		if err = decodeResult(res, &lld); err != nil {
+			t.Fatalf("cannot decode result: %s", err)
+		}
+
+		if len(lld) != 1 || lld[0]["{#SERVICE.LABEL.ZABBIX.ON_CALL}"] != "first" {
+			t.Fatalf("discoverServices() = %v, want the macro set by zabbix.on-call", lld)
+		}
+	}
+}
+
+func TestDiscoverStacks(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	res, err := p.discoverStacks(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("discoverStacks() error = %v", err)
+	}
+
+	var lld []map[string]string
+	if err = decodeResult(res, &lld); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	stacks := make([]string, 0, len(lld))
+	for _, s := range lld {
+		stacks = append(stacks, s["{#STACK.NAME}"])
+	}
+
+	sort.Strings(stacks)
+
+	if got := strings.Join(stacks, ","); got != "jobs,mystack,standalone" {
+		t.Fatalf("discoverStacks() = %s, want jobs,mystack,standalone", got)
+	}
+}
+
+// warningRecorder records the warnings logged by the plugin.
+type warningRecorder struct {
+	testLogger
+
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (l *warningRecorder) Warningf(format string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+func TestDiscoverStacksStandaloneCollision(t *testing.T) {
+	t.Parallel()
+
+	stacked := `{
+		"ID": "svcreal001",
+		"Spec": {"Name": "real", "Labels": {"com.docker.stack.namespace": "standalone"}}
+	}`
+	worker := `{"ID": "svcworker1", "Spec": {"Name": "worker"}}`
+
+	tests := []struct {
+		name           string
+		stackedFirst   bool
+		withStandalone bool
+		wantWarnings   int
+	}{
+		{"standaloneFirst", false, true, 1},
+		{"stackedFirst", true, true, 1},
+		{"noStandalone", true, false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := newFakeDocker(t, "swarm")
+			p := newTestPlugin(t, f)
+			logger := &warningRecorder{testLogger: testLogger{t}}
+			p.Logger = logger
+
+			f.removeService("svcworker1")
+
+			if !tt.stackedFirst && tt.withStandalone {
+				f.addService(worker)
+			}
+
+			f.addService(stacked)
+
+			if tt.stackedFirst && tt.withStandalone {
+				f.addService(worker)
+			}
+
+			if _, err := p.discoverStacks(context.Background(), nil); err != nil {
+				t.Fatalf("discoverStacks() error = %v", err)
+			}
+
+			if len(logger.warnings) != tt.wantWarnings {
+				t.Fatalf("discoverStacks() warnings = %v, want %d", logger.warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestServiceCountHandlers(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	handlers := map[string]func(context.Context, []string) (any, error){
+		"getDesiredReplicas":    p.getDesiredReplicas,
+		"getRunningTasks":       p.getRunningTasks,
+		"getServiceRestarts":    p.getServiceRestarts,
+		"getServiceTaskCount":   p.getServiceTaskCount,
+		"getServiceMaintenance": p.getServiceMaintenance,
+	}
+
+	tests := []struct {
+		handler string
+		service string
+		want    int
+	}{
+		{"getDesiredReplicas", "web", 3},
+		{"getDesiredReplicas", "mystack_web", 3},
+		{"getDesiredReplicas", svcWebID, 3},
+		{"getDesiredReplicas", "worker", 1},
+		{"getRunningTasks", "web", 2},
+		{"getRunningTasks", "db", 0},
+		{"getServiceRestarts", "web", 2},
+		{"getServiceRestarts", "db", 2},
+		{"getServiceTaskCount", "web", 4},
+		{"getServiceMaintenance", "web", 0},
+		{"getServiceMaintenance", "batch", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.handler+"_"+tt.service, func(t *testing.T) {
+			t.Parallel()
+
+			res, err := handlers[tt.handler](context.Background(), []string{tt.service})
+			if err != nil {
+				t.Fatalf("%s() error = %v", tt.handler, err)
+			}
+
+			if res != tt.want {
+				t.Fatalf("%s() = %v, want %v", tt.handler, res, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindServiceByIdentifier(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "swarm")
+
+	// A standalone service whose name is the key of the web service of mystack, and whose ID
+	// starts like the name of the db service
+	f.addService(`{"ID": "db0000001", "Spec": {"Name": "mystack_web", "Mode": {"Replicated": {"Replicas": 1}}}}`)
+
+	p := newTestPlugin(t, f)
+
+	tests := []struct {
+		identifier string
+		wantID     string
+		wantErr    bool
+	}{
+		{"web", svcWebID, false},
+		{"id:" + svcWebID, svcWebID, false},
+		{"name:web", svcWebID, false},
+		{"key:db", "", true},
+		{"key:mystack_db", "svcdb00001", false},
+		{"svcmai", "svcmaint01", false},
+		{"id:svcmai", "svcmaint01", false},
+		{"name:svcmai", "", true},
+		// The name of svcdb00001 and a prefix of the ID of db0000001
+		{"db", "", true},
+		{"name:db", "svcdb00001", false},
+		{"db0", "db0000001", false},
+		{"svc", "", true},
+		{"mystack_web", "", true},
+		{"key:mystack_web", "", true},
+		{"name:mystack_web", "db0000001", false},
+		{"id:", "", true},
+		{"missing", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.identifier, func(t *testing.T) {
+			t.Parallel()
+
+			service, err := p.findServiceByIdentifier(tt.identifier)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findServiceByIdentifier() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && service.ID != tt.wantID {
+				t.Fatalf("findServiceByIdentifier() = %s, want %s", service.ID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestServiceHandlersErrors(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	handlers := map[string]func(context.Context, []string) (any, error){
+		"getDesiredReplicas":    p.getDesiredReplicas,
+		"getRunningTasks":       p.getRunningTasks,
+		"getServiceRestarts":    p.getServiceRestarts,
+		"getServiceTaskCount":   p.getServiceTaskCount,
+		"getServiceLastRestart": p.getServiceLastRestart,
+	}
+
+	for name, handler := range handlers {
+		res, err := handler(context.Background(), []string{"missing"})
+		if !isNotFound(err) {
+			t.Errorf("%s() with unknown service: error = %v, want not found error", name, err)
+		}
+
+		if res != nil {
+			t.Errorf("%s() with unknown service = %v, want no value", name, res)
+		}
+
+		if _, err := handler(context.Background(), nil); err == nil {
+			t.Errorf("%s() without parameters: expected error", name)
+		}
+	}
+}
+
+func TestGetServiceLastRestart(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	res, err := p.getServiceLastRestart(context.Background(), []string{"web"})
+	if err != nil {
+		t.Fatalf("getServiceLastRestart() error = %v", err)
+	}
+
+	// Creation time of the newest running task, not its later status update
+	want := time.Date(2024, 3, 5, 12, 5, 0, 0, time.UTC).Unix()
+	if res != want {
+		t.Fatalf("getServiceLastRestart() = %v, want %v", res, want)
+	}
+}
+
+func TestGetStackHealth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		params      []string
+		wantStatus  string
+		wantPercent float64
+		wantStates  map[string]string
+	}{
+		{
+			name:        "criticalDown",
+			params:      []string{"mystack"},
+			wantStatus:  "critical",
+			wantPercent: 0,
+			wantStates:  map[string]string{"web": serviceStateDegraded, "db": serviceStateDown},
+		},
+		{
+			name:        "anyMode",
+			params:      []string{"mystack", "any"},
+			wantStatus:  "critical",
+			wantPercent: 0,
+			wantStates:  map[string]string{"web": serviceStateHealthy, "db": serviceStateDown},
+		},
+		{
+			name:        "maintenance",
+			params:      []string{"jobs"},
+			wantStatus:  "ok",
+			wantPercent: 100,
+			wantStates:  map[string]string{"batch": serviceStateMaintenance},
+		},
+		{
+			name:        "standalone",
+			params:      []string{"standalone"},
+			wantStatus:  "ok",
+			wantPercent: 100,
+			wantStates:  map[string]string{"worker": serviceStateHealthy},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+			res, err := p.getStackHealth(context.Background(), tt.params)
+			if err != nil {
+				t.Fatalf("getStackHealth() error = %v", err)
+			}
+
+			var health StackHealth
+			if err = decodeResult(res, &health); err != nil {
+				t.Fatalf("cannot decode result: %s", err)
+			}
+
+			if health.Status != tt.wantStatus || health.HealthPercentage != tt.wantPercent {
+				t.Fatalf("getStackHealth() status = %s (%v%%), want %s (%v%%)",
+					health.Status, health.HealthPercentage, tt.wantStatus, tt.wantPercent)
+			}
+
+			if len(health.Services) != len(tt.wantStates) {
+				t.Fatalf("getStackHealth() returned %d services, want %d", len(health.Services), len(tt.wantStates))
+			}
+
+			for _, s := range health.Services {
+				if s.State != tt.wantStates[s.Name] {
+					t.Errorf("service %s state = %s, want %s", s.Name, s.State, tt.wantStates[s.Name])
+				}
+			}
+		})
+	}
+}
+
+func TestGetStackHealthWeighted(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	// web is healthy with >= 50% of its replicas running, the critical db service is still down
+	health, err := p.stackHealth("mystack", healthCriteria{percent: 50})
+	if err != nil {
+		t.Fatalf("stackHealth() error = %v", err)
+	}
+
+	if health.StatusCode != stackStatusCritical {
+		t.Fatalf("stackHealth() status = %d, want %d", health.StatusCode, stackStatusCritical)
+	}
+
+	if health.HealthyServices != 1 || health.DownServices != 1 || health.UnhealthyServices != 1 {
+		t.Fatalf("stackHealth() healthy/down/unhealthy = %d/%d/%d, want 1/1/1",
+			health.HealthyServices, health.DownServices, health.UnhealthyServices)
+	}
+}
+
+func TestGetStackStatus(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	res, err := p.getStackStatus(context.Background(), []string{"standalone"})
+	if err != nil {
+		t.Fatalf("getStackStatus() error = %v", err)
+	}
+
+	if res != stackStatusOK {
+		t.Fatalf("getStackStatus() = %v, want %d", res, stackStatusOK)
+	}
+
+	if _, err = p.getStackStatus(context.Background(), []string{"missing"}); err == nil {
+		t.Fatalf("getStackStatus() with unknown stack: expected error")
+	}
+
+	if _, err = p.getStackStatus(context.Background(), []string{"mystack", "101"}); err == nil {
+		t.Fatalf("getStackStatus() with invalid mode: expected error")
+	}
+}
+
+func TestDockerAPIError(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "swarm")
+	f.fail("services", http.StatusInternalServerError)
+
+	p := newTestPlugin(t, f)
+
+	_, err := p.getDesiredReplicas(context.Background(), []string{"web"})
+	if err == nil || !strings.Contains(err.Error(), "fake failure of services") {
+		t.Fatalf("getDesiredReplicas() error = %v, want Docker API error message", err)
+	}
+
+	_, err = p.discoverServices(context.Background(), nil)
+	if err == nil {
+		t.Fatalf("discoverServices() expected error")
+	}
+}
+
+func TestGetStackHealthUnknownService(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "swarm")
+	f.fail("tasks", http.StatusServiceUnavailable)
+
+	p := newTestPlugin(t, f)
+
+	health, err := p.stackHealth("standalone", healthCriteria{percent: 100})
+	if err != nil {
+		t.Fatalf("stackHealth() error = %v", err)
+	}
+
+	if health.UnknownServices != 1 || health.StatusCode != stackStatusUnknown {
+		t.Fatalf("stackHealth() unknown = %d status = %d, want 1 and %d",
+			health.UnknownServices, health.StatusCode, stackStatusUnknown)
+	}
+
+	if !strings.Contains(health.Services[0].Reason, "fake failure of tasks") {
+		t.Fatalf("stackHealth() reason = %q, want the Docker API error", health.Services[0].Reason)
+	}
+}
+
+const svcWebID = "svcweb0001"
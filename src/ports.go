@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+const (
+	// portCheckTimeout is the maximum time to wait for a TCP connection of a port check.
+	portCheckTimeout = 5 * time.Second
+	// portCheckHost is the address of the local node's routing mesh.
+	portCheckHost = "127.0.0.1"
+)
+
+func (p *swarmPlugin) discoverPorts(_ context.Context, params []string) (any, error) {
+	if len(params) != 0 {
+		return nil, errs.New("expected no parameters for port discovery")
+	}
+
+	services, err := p.getServices(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	lldPorts := make([]LLDPort, 0, len(services))
+	for _, s := range services {
+		if !isMonitored(s) {
+			continue
+		}
+
+		stackName, serviceKey := p.resolveService(s.Spec.Name, s.Spec.Labels)
+
+		for _, port := range s.Endpoint.Ports {
+			if port.PublishedPort == 0 {
+				continue
+			}
+
+			lldPorts = append(lldPorts, LLDPort{
+				ServiceName: s.Spec.Name,
+				ServiceKey:  serviceKey,
+				StackName:   stackName,
+				Target:      port.TargetPort,
+				Published:   port.PublishedPort,
+				Protocol:    port.Protocol,
+				Mode:        port.PublishMode,
+			})
+		}
+	}
+
+	return jsonResult(lldPorts)
+}
+
+// getServicePortCheck connects to a TCP port published by a service on the local node and
+// returns 1 if the connection succeeded, 0 otherwise.
+func (p *swarmPlugin) getServicePortCheck(ctx context.Context, params []string) (any, error) {
+	if len(params) != 2 {
+		return nil, errs.New("expected 2 parameters for service port check")
+	}
+
+	port, err := strconv.ParseUint(params[1], 10, 16)
+	if err != nil || port == 0 {
+		return nil, errs.New("invalid port " + params[1])
+	}
+
+	service, err := p.findServiceByIdentifier(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	published := false
+
+	for _, pc := range service.Endpoint.Ports {
+		if uint64(pc.PublishedPort) == port && pc.Protocol == "tcp" {
+			published = true
+
+			break
+		}
+	}
+
+	if !published {
+		return nil, errs.New("TCP port " + params[1] + " is not published by service " + params[0])
+	}
+
+	if probeTCP(ctx, net.JoinHostPort(portCheckHost, params[1])) {
+		return 1, nil
+	}
+
+	return 0, nil
+}
+
+// probeTCP reports whether a TCP connection to address can be established.
+func probeTCP(ctx context.Context, address string) bool {
+	dialer := net.Dialer{Timeout: portCheckTimeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return false
+	}
+
+	_ = conn.Close()
+
+	return true
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestDiscoverPorts(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	res, err := p.discoverPorts(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("discoverPorts() error = %v", err)
+	}
+
+	var lld []map[string]any
+	if err = decodeResult(res, &lld); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	if len(lld) != 1 || lld[0]["{#SERVICE.KEY}"] != "mystack_web" || lld[0]["{#PORT.PUBLISHED}"] != float64(8080) {
+		t.Fatalf("discoverPorts() = %v, want port 8080 of mystack_web", lld)
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot listen: %s", err)
+	}
+
+	address := listener.Addr().String()
+
+	if !probeTCP(context.Background(), address) {
+		t.Fatalf("probeTCP() = false for a listening port")
+	}
+
+	_ = listener.Close()
+
+	if probeTCP(context.Background(), address) {
+		t.Fatalf("probeTCP() = true for a closed port")
+	}
+}
+
+func TestGetServicePortCheckUnpublished(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	if _, err := p.getServicePortCheck(context.Background(), []string{"web", "9090"}); err == nil {
+		t.Fatalf("getServicePortCheck() of an unpublished port: expected error")
+	}
+}
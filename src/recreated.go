@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+// maxPreviousIDs is the number of previous IDs kept per service key.
+const maxPreviousIDs = 10
+
+// serviceIdentity is the current and previous IDs of a service key. A service removed and deployed
+// again (e.g. by docker stack rm and docker stack deploy) gets a new ID, an update keeps it.
+type serviceIdentity struct {
+	currentID     string
+	previousIDs   []string
+	recreations   int
+	lastRecreated time.Time
+	// sharedIDs are the IDs of the services having the key in the last listing, if more than one.
+	sharedIDs []string
+	// missingSince is the time the key was first missing from the listings, zero while listed.
+	missingSince time.Time
+}
+
+// recordIdentities records the IDs of the listed services by service key and counts a re-creation
+// for every key whose ID changed since the previous listing. Services deployed before the first
+// listing seen by the plugin are not counted. A key shared by several services is ambiguous, its
+// IDs are not recorded until a single service has it again. Keys missing from the listings for
+// longer than the grace period are forgotten, so a service re-created within it is still counted.
+func (t *serviceTracker) recordIdentities(services []trackedService, gracePeriod time.Duration, now time.Time) {
+	if t.identities == nil {
+		t.identities = map[string]*serviceIdentity{}
+	}
+
+	byKey := map[string][]trackedService{}
+	for _, s := range services {
+		byKey[s.key] = append(byKey[s.key], s)
+	}
+
+	for key, keyServices := range byKey {
+		identity, exists := t.identities[key]
+		if !exists {
+			identity = &serviceIdentity{}
+			t.identities[key] = identity
+		}
+
+		identity.sharedIDs = nil
+		identity.missingSince = time.Time{}
+
+		if len(keyServices) > 1 {
+			for _, s := range keyServices {
+				identity.sharedIDs = append(identity.sharedIDs, s.id)
+			}
+
+			sort.Strings(identity.sharedIDs)
+
+			continue
+		}
+
+		s := keyServices[0]
+
+		if identity.currentID == "" {
+			identity.currentID = s.id
+
+			continue
+		}
+
+		if identity.currentID == s.id {
+			continue
+		}
+
+		identity.previousIDs = append(identity.previousIDs, identity.currentID)
+		if len(identity.previousIDs) > maxPreviousIDs {
+			identity.previousIDs = identity.previousIDs[len(identity.previousIDs)-maxPreviousIDs:]
+		}
+
+		identity.currentID = s.id
+		identity.recreations++
+
+		identity.lastRecreated = now
+		if created, err := time.Parse(time.RFC3339Nano, s.createdAt); err == nil {
+			identity.lastRecreated = created
+		}
+	}
+
+	for key, identity := range t.identities {
+		if _, listed := byKey[key]; listed {
+			continue
+		}
+
+		if identity.missingSince.IsZero() {
+			identity.missingSince = now
+		} else if now.Sub(identity.missingSince) > gracePeriod {
+			delete(t.identities, key)
+		}
+	}
+}
+
+// recreation returns the re-creations of a service key, or an error if the key is ambiguous.
+func (t *serviceTracker) recreation(key string) (ServiceRecreation, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recreation := ServiceRecreation{PreviousIDs: []string{}}
+
+	identity, exists := t.identities[key]
+	if !exists {
+		return recreation, nil
+	}
+
+	if len(identity.sharedIDs) > 0 {
+		return recreation, errs.New("ambiguous service key " + key + " matches services " +
+			strings.Join(identity.sharedIDs, ", "))
+	}
+
+	recreation.CurrentID = identity.currentID
+	recreation.PreviousIDs = append(recreation.PreviousIDs, identity.previousIDs...)
+	recreation.Count = identity.recreations
+
+	if identity.recreations > 0 {
+		recreation.LastRecreated = identity.lastRecreated.Unix()
+	}
+
+	return recreation, nil
+}
+
+func (p *swarmPlugin) getServiceRecreated(_ context.Context, params []string) (any, error) {
+	if len(params) != 1 {
+		return nil, errs.New("expected 1 parameter for service re-creation")
+	}
+
+	// Looking up the service lists all services, which records their current IDs
+	targetService, err := p.findServiceByIdentifier(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	recreation, err := p.services.recreation(p.trackService(*targetService).key)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonResult(recreation)
+}
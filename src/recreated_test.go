@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetServiceRecreated(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "swarm")
+	p := newTestPlugin(t, f)
+
+	recreated := func() ServiceRecreation {
+		t.Helper()
+
+		res, err := p.getServiceRecreated(context.Background(), []string{"mystack_web"})
+		if err != nil {
+			t.Fatalf("getServiceRecreated() error = %s", err)
+		}
+
+		var recreation ServiceRecreation
+		if err = decodeResult(res, &recreation); err != nil {
+			t.Fatalf("cannot decode result: %s", err)
+		}
+
+		return recreation
+	}
+
+	if got := recreated(); got.Count != 0 || got.CurrentID != "svcweb0001" || len(got.PreviousIDs) != 0 {
+		t.Fatalf("getServiceRecreated() before re-creation = %+v", got)
+	}
+
+	// docker stack rm && docker stack deploy
+	f.removeService("svcweb0001")
+	f.addService(`{
+		"ID": "svcweb0002",
+		"CreatedAt": "2024-05-01T10:00:00Z",
+		"Spec": {
+			"Name": "web",
+			"Labels": {"com.docker.stack.namespace": "mystack"},
+			"Mode": {"Replicated": {"Replicas": 3}}
+		}
+	}`)
+
+	got := recreated()
+	if got.Count != 1 || got.CurrentID != "svcweb0002" || got.LastRecreated != 1714557600 {
+		t.Fatalf("getServiceRecreated() after re-creation = %+v", got)
+	}
+
+	if len(got.PreviousIDs) != 1 || got.PreviousIDs[0] != "svcweb0001" {
+		t.Fatalf("getServiceRecreated() previous IDs = %v, want [svcweb0001]", got.PreviousIDs)
+	}
+
+	// An update keeps the ID and is not a re-creation
+	if got = recreated(); got.Count != 1 {
+		t.Fatalf("getServiceRecreated() without change = %+v, want count 1", got)
+	}
+
+	if _, err := p.getServiceRecreated(context.Background(), []string{"missing"}); !isNotFound(err) {
+		t.Fatalf("getServiceRecreated() with unknown service: error = %v, want not found error", err)
+	}
+}
+
+func TestGetServiceRecreatedSharedKey(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "swarm")
+
+	// A standalone service whose key is the key of the web service of mystack
+	f.addService(`{"ID": "svcshared1", "Spec": {"Name": "mystack_web", "Mode": {"Replicated": {"Replicas": 1}}}}`)
+
+	p := newTestPlugin(t, f)
+
+	for range 3 {
+		if _, err := p.getServiceRecreated(context.Background(), []string{svcWebID}); err == nil {
+			t.Fatal("getServiceRecreated() with a shared key: expected ambiguity error")
+		}
+	}
+
+	f.removeService("svcshared1")
+
+	res, err := p.getServiceRecreated(context.Background(), []string{svcWebID})
+	if err != nil {
+		t.Fatalf("getServiceRecreated() error = %s", err)
+	}
+
+	var got ServiceRecreation
+	if err = decodeResult(res, &got); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	if got.Count != 0 || got.CurrentID != svcWebID || len(got.PreviousIDs) != 0 {
+		t.Fatalf("getServiceRecreated() after the shared key was resolved = %+v, want no re-creation", got)
+	}
+}
+
+func TestRecordIdentitiesForgetsRemovedKeys(t *testing.T) {
+	t.Parallel()
+
+	web := trackedService{id: "svcweb0001", name: "web", key: "mystack_web"}
+	db := trackedService{id: "svcdb00001", name: "db", key: "mystack_db"}
+
+	grace := 10 * time.Minute
+	now := time.Now()
+
+	var tracker serviceTracker
+
+	tracker.observe([]trackedService{web, db}, grace, now)
+
+	// A service re-created within the grace period is counted
+	recreatedWeb := web
+	recreatedWeb.id = "svcweb0002"
+
+	tracker.observe([]trackedService{db}, grace, now.Add(3*time.Minute))
+	tracker.observe([]trackedService{recreatedWeb, db}, grace, now.Add(5*time.Minute))
+
+	if got, err := tracker.recreation("mystack_web"); err != nil || got.Count != 1 {
+		t.Fatalf("recreation() within the grace period = %+v, %v, want count 1", got, err)
+	}
+
+	// Keys missing for longer than the grace period are forgotten
+	tracker.observe([]trackedService{db}, grace, now.Add(6*time.Minute))
+	tracker.observe([]trackedService{db}, grace, now.Add(6*time.Minute+grace+time.Second))
+
+	if _, exists := tracker.identities["mystack_web"]; exists || len(tracker.identities) != 1 {
+		t.Fatalf("identities = %v, want only mystack_db", tracker.identities)
+	}
+}
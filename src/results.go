@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+// Handlers return one of the following, so every item has a single value type:
+//   - int, int64 or float64 for numeric items,
+//   - a JSON string made by jsonResult from a typed struct (or slice of them) for discovery and
+//     structured items,
+//   - nil without an error when there is no new value, for log items only.
+//
+// An object that does not exist or cannot be evaluated is reported as an error, making the item
+// unsupported in Zabbix. Handlers never return a zero value along with an error, so a missing
+// service can not be mistaken for a service with zero replicas.
+
+// notFoundError is returned when the object an item refers to does not exist.
+type notFoundError struct {
+	kind       string
+	identifier string
+}
+
+func newNotFoundError(kind, identifier string) error {
+	return &notFoundError{kind: kind, identifier: identifier}
+}
+
+func (e *notFoundError) Error() string {
+	return e.kind + " not found: " + e.identifier
+}
+
+// isNotFound reports whether err is caused by a missing object.
+func isNotFound(err error) bool {
+	var notFound *notFoundError
+
+	return errors.As(err, &notFound)
+}
+
+// jsonResult marshals a typed result into the JSON string returned by handlers.
+func jsonResult(v any) (any, error) {
+	jsonData, err := json.Marshal(v)
+	if err != nil {
+		return nil, errs.Wrap(err, "cannot marshal JSON")
+	}
+
+	return string(jsonData), nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+// swarmObjectKind describes a kind of swarm object (secret or config) shared by the inventory metrics.
+type swarmObjectKind struct {
+	// name is used in error messages.
+	name string
+	// path is the Docker API endpoint listing the objects.
+	path string
+	// lld returns the discovery entry of an object.
+	lld func(o SwarmObject) any
+	// references returns the IDs of the objects referenced by a service.
+	references func(s Service) []string
+}
+
+var (
+	secretKind = swarmObjectKind{
+		name: "secret",
+		path: "secrets",
+		lld: func(o SwarmObject) any {
+			return LLDSecret{ID: o.ID, Name: o.Spec.Name}
+		},
+		references: func(s Service) []string {
+			ids := make([]string, 0, len(s.Spec.TaskTemplate.ContainerSpec.Secrets))
+			for _, ref := range s.Spec.TaskTemplate.ContainerSpec.Secrets {
+				ids = append(ids, ref.SecretID)
+			}
+
+			return ids
+		},
+	}
+
+	configKind = swarmObjectKind{
+		name: "config",
+		path: "configs",
+		lld: func(o SwarmObject) any {
+			return LLDConfig{ID: o.ID, Name: o.Spec.Name}
+		},
+		references: func(s Service) []string {
+			ids := make([]string, 0, len(s.Spec.TaskTemplate.ContainerSpec.Configs))
+			for _, ref := range s.Spec.TaskTemplate.ContainerSpec.Configs {
+				ids = append(ids, ref.ConfigID)
+			}
+
+			return ids
+		},
+	}
+)
+
+func (p *swarmPlugin) getSwarmObjects(kind swarmObjectKind) ([]SwarmObject, error) {
+	body, err := p.client.Query(kind.path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []SwarmObject
+	if err = json.Unmarshal(body, &objects); err != nil {
+		return nil, errs.Wrap(err, "cannot unmarshal JSON")
+	}
+
+	return objects, nil
+}
+
+// findSwarmObject finds a secret or config by ID or name.
+func (p *swarmPlugin) findSwarmObject(kind swarmObjectKind, identifier string) (*SwarmObject, error) {
+	objects, err := p.getSwarmObjects(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range objects {
+		if objects[i].ID == identifier || objects[i].Spec.Name == identifier {
+			return &objects[i], nil
+		}
+	}
+
+	return nil, newNotFoundError(kind.name, identifier)
+}
+
+// discoverSwarmObjects returns the LLD handler for secrets or configs.
+func (p *swarmPlugin) discoverSwarmObjects(kind swarmObjectKind) func(context.Context, []string) (any, error) {
+	return func(_ context.Context, params []string) (any, error) {
+		if len(params) != 0 {
+			return nil, errs.New("expected no parameters for " + kind.name + " discovery")
+		}
+
+		objects, err := p.getSwarmObjects(kind)
+		if err != nil {
+			return nil, err
+		}
+
+		lldObjects := make([]any, 0, len(objects))
+		for _, o := range objects {
+			lldObjects = append(lldObjects, kind.lld(o))
+		}
+
+		return jsonResult(lldObjects)
+	}
+}
+
+// getSwarmObjectAge returns the handler for the age in seconds of a secret or config since it
+// was created, or since it was last updated when updated is true.
+func (p *swarmPlugin) getSwarmObjectAge(kind swarmObjectKind, updated bool) func(context.Context, []string) (any, error) {
+	return func(_ context.Context, params []string) (any, error) {
+		if len(params) != 1 {
+			return nil, errs.New("expected 1 parameter for " + kind.name + " age")
+		}
+
+		object, err := p.findSwarmObject(kind, params[0])
+		if err != nil {
+			return nil, err
+		}
+
+		timestamp := object.CreatedAt
+		if updated {
+			timestamp = object.UpdatedAt
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return nil, errs.Wrap(err, "cannot parse "+kind.name+" timestamp")
+		}
+
+		return int64(time.Since(t).Seconds()), nil
+	}
+}
+
+// getSwarmObjectServices returns the handler for the number of services referencing a secret or config.
+func (p *swarmPlugin) getSwarmObjectServices(kind swarmObjectKind) func(context.Context, []string) (any, error) {
+	return func(_ context.Context, params []string) (any, error) {
+		if len(params) != 1 {
+			return nil, errs.New("expected 1 parameter for " + kind.name + " services")
+		}
+
+		object, err := p.findSwarmObject(kind, params[0])
+		if err != nil {
+			return nil, err
+		}
+
+		services, err := p.getServices(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		count := 0
+
+		for _, s := range services {
+			for _, id := range kind.references(s) {
+				if id == object.ID {
+					count++
+
+					break
+				}
+			}
+		}
+
+		return count, nil
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSwarmObjectServices(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	tests := []struct {
+		kind       swarmObjectKind
+		identifier string
+		want       int
+	}{
+		{secretKind, "db_password", 2},
+		{secretKind, "secretold1", 0},
+		{configKind, "tls_cert", 1},
+		{configKind, "nginx_conf", 0},
+	}
+
+	for _, tt := range tests {
+		res, err := p.getSwarmObjectServices(tt.kind)(context.Background(), []string{tt.identifier})
+		if err != nil {
+			t.Fatalf("%s services error = %v", tt.kind.name, err)
+		}
+
+		if res != tt.want {
+			t.Errorf("%s %s services = %v, want %v", tt.kind.name, tt.identifier, res, tt.want)
+		}
+	}
+}
+
+func TestSwarmObjectAge(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	created, err := p.getSwarmObjectAge(secretKind, false)(context.Background(), []string{"db_password"})
+	if err != nil {
+		t.Fatalf("secret age error = %v", err)
+	}
+
+	updated, err := p.getSwarmObjectAge(secretKind, true)(context.Background(), []string{"db_password"})
+	if err != nil {
+		t.Fatalf("secret update age error = %v", err)
+	}
+
+	// Created 2024-01-01, updated a month later
+	if diff := created.(int64) - updated.(int64); diff != 31*24*3600 {
+		t.Fatalf("secret age - update age = %d, want %d", diff, 31*24*3600)
+	}
+
+	if _, err = p.getSwarmObjectAge(secretKind, false)(context.Background(), []string{"missing"}); err == nil {
+		t.Fatalf("secret age of unknown secret: expected error")
+	}
+}
+
+func TestCertificates(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	res, err := p.discoverCertificates(context.Background(), []string{"", "purpose=ingress"})
+	if err != nil {
+		t.Fatalf("discoverCertificates() error = %v", err)
+	}
+
+	var lld []map[string]string
+	if err = decodeResult(res, &lld); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	if len(lld) != 1 || lld[0]["{#CONFIG.NAME}"] != "tls_cert" {
+		t.Fatalf("discoverCertificates() = %v, want only tls_cert", lld)
+	}
+
+	expiry, err := p.getCertificateExpiry(context.Background(), []string{"tls_cert"})
+	if err != nil {
+		t.Fatalf("getCertificateExpiry() error = %v", err)
+	}
+
+	if expiry != int64(4945936689) {
+		t.Fatalf("getCertificateExpiry() = %v, want 4945936689", expiry)
+	}
+
+	if _, err = p.getCertificateExpiry(context.Background(), []string{"nginx_conf"}); err == nil {
+		t.Fatalf("getCertificateExpiry() of config without certificate: expected error")
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+// composeProjectLabel is the label Docker Compose sets to the project name.
+const composeProjectLabel = "com.docker.compose.project"
+
+// resolveStack returns the stack of a service from its labels, the configured stack label or else
+// the Compose project label, and whether it belongs to a stack. Services without either label
+// belong to the standalone pseudo-stack.
+func (p *swarmPlugin) resolveStack(labels map[string]string) (string, bool) {
+	for _, label := range []string{p.options.stackLabel(), composeProjectLabel} {
+		if stackName := labels[label]; stackName != "" {
+			return stackName, true
+		}
+	}
+
+	return p.options.standaloneStackName(), false
+}
+
+// resolveService returns the stack of a service and its stable key: <stack>_<name> for services
+// of a stack and the service name for standalone services.
+func (p *swarmPlugin) resolveService(name string, labels map[string]string) (string, string) {
+	stackName, stacked := p.resolveStack(labels)
+	if !stacked {
+		return stackName, name
+	}
+
+	return stackName, stackName + "_" + name
+}
+
+// getStackServices returns the monitored services of a stack. The services are filtered after
+// fetching all services, as the stack may be given by either the stack or the Compose label.
+func (p *swarmPlugin) getStackServices(stackName string) ([]Service, error) {
+	services, err := p.getServices(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stackServices := make([]Service, 0, len(services))
+	for _, s := range services {
+		if !isMonitored(s) {
+			continue
+		}
+
+		if serviceStack, _ := p.resolveStack(s.Spec.Labels); serviceStack != stackName {
+			continue
+		}
+
+		stackServices = append(stackServices, s)
+	}
+
+	if len(stackServices) == 0 {
+		return nil, newNotFoundError("stack", stackName)
+	}
+
+	return stackServices, nil
+}
+
+// getServicesTasks returns the tasks of all given services with a single request.
+func (p *swarmPlugin) getServicesTasks(services []Service) ([]Task, error) {
+	ids := make([]string, 0, len(services))
+	for _, s := range services {
+		ids = append(ids, s.ID)
+	}
+
+	tasks, err := p.getTasks(map[string][]string{"service": ids})
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+func (p *swarmPlugin) getStackReplicas(_ context.Context, params []string) (any, error) {
+	if len(params) != 1 {
+		return nil, errs.New("expected 1 parameter for stack replicas")
+	}
+
+	services, err := p.getStackServices(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := p.getServicesTasks(services)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicas StackReplicas
+
+	// Like in the stack health, services whose desired replicas cannot be determined are counted as
+	// unknown and their tasks are left out of the running replicas
+	unknown := map[string]bool{}
+
+	for _, s := range services {
+		desired, dErr := p.getServiceDesiredReplicas(s)
+		if dErr != nil {
+			p.Debugf("stack %s: %s", params[0], dErr.Error())
+
+			unknown[s.ID] = true
+			replicas.Unknown++
+
+			continue
+		}
+
+		replicas.Desired += desired
+	}
+
+	for _, task := range tasks {
+		if unknown[task.ServiceID] {
+			continue
+		}
+
+		if task.DesiredState == "running" && task.Status.State == "running" {
+			replicas.Running++
+		}
+	}
+
+	return jsonResult(replicas)
+}
+
+func (p *swarmPlugin) getStackRestarts(_ context.Context, params []string) (any, error) {
+	if len(params) != 1 {
+		return nil, errs.New("expected 1 parameter for stack restarts")
+	}
+
+	services, err := p.getStackServices(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := p.getServicesTasks(services)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same semantics as the service restarts: every task in the history that is not running
+	restartCount := 0
+	for _, task := range tasks {
+		if task.Status.State != "running" {
+			restartCount++
+		}
+	}
+
+	return restartCount, nil
+}
+
+func (p *swarmPlugin) getStackLastDeploy(_ context.Context, params []string) (any, error) {
+	if len(params) != 1 {
+		return nil, errs.New("expected 1 parameter for stack last deploy")
+	}
+
+	services, err := p.getStackServices(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// The most recent service creation or update is the last deployment of the stack
+	var lastDeploy int64
+
+	for _, s := range services {
+		for _, ts := range []string{s.CreatedAt, s.UpdatedAt} {
+			timestamp, pErr := time.Parse(time.RFC3339Nano, ts)
+			if pErr != nil {
+				continue
+			}
+
+			if timestamp.Unix() > lastDeploy {
+				lastDeploy = timestamp.Unix()
+			}
+		}
+	}
+
+	return lastDeploy, nil
+}
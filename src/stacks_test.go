@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetStackReplicas(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "swarm")
+	p := newTestPlugin(t, f)
+
+	res, err := p.getStackReplicas(context.Background(), []string{"mystack"})
+	if err != nil {
+		t.Fatalf("getStackReplicas() error = %v", err)
+	}
+
+	var replicas StackReplicas
+	if err = decodeResult(res, &replicas); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	// The hidden service opted out of monitoring and is not counted
+	if replicas.Desired != 4 || replicas.Running != 2 {
+		t.Fatalf("getStackReplicas() = %+v, want desired 4 running 2", replicas)
+	}
+
+	if f.requestCount("services") != 1 || f.requestCount("tasks") != 1 {
+		t.Fatalf("getStackReplicas() made %d services and %d tasks requests, want 1 each",
+			f.requestCount("services"), f.requestCount("tasks"))
+	}
+}
+
+func TestGetStackReplicasUnknownService(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "swarm")
+	p := newTestPlugin(t, f)
+
+	// A service without a mode has no desired replicas
+	f.addService(`{
+		"ID": "svcjob0001",
+		"Spec": {"Name": "job", "Labels": {"com.docker.stack.namespace": "mystack"}}
+	}`)
+
+	res, err := p.getStackReplicas(context.Background(), []string{"mystack"})
+	if err != nil {
+		t.Fatalf("getStackReplicas() error = %v", err)
+	}
+
+	var replicas StackReplicas
+	if err = decodeResult(res, &replicas); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	if replicas.Desired != 4 || replicas.Running != 2 || replicas.Unknown != 1 {
+		t.Fatalf("getStackReplicas() = %+v, want desired 4 running 2 unknown 1", replicas)
+	}
+}
+
+func TestGetStackRestarts(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	res, err := p.getStackRestarts(context.Background(), []string{"mystack"})
+	if err != nil {
+		t.Fatalf("getStackRestarts() error = %v", err)
+	}
+
+	if res != 4 {
+		t.Fatalf("getStackRestarts() = %v, want 4", res)
+	}
+}
+
+func TestGetStackLastDeploy(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	tests := []struct {
+		stack string
+		want  time.Time
+	}{
+		{"mystack", time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)},
+		{"standalone", time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		res, err := p.getStackLastDeploy(context.Background(), []string{tt.stack})
+		if err != nil {
+			t.Fatalf("getStackLastDeploy(%s) error = %v", tt.stack, err)
+		}
+
+		if res != tt.want.Unix() {
+			t.Errorf("getStackLastDeploy(%s) = %v, want %v", tt.stack, res, tt.want.Unix())
+		}
+	}
+
+	if _, err := p.getStackLastDeploy(context.Background(), []string{"missing"}); err == nil {
+		t.Fatalf("getStackLastDeploy() with unknown stack: expected error")
+	}
+}
+
+func TestResolveService(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		options     pluginOptions
+		labels      map[string]string
+		wantStack   string
+		wantKey     string
+		wantStacked bool
+	}{
+		{"stack", pluginOptions{}, map[string]string{defaultStackLabel: "mystack"}, "mystack", "mystack_web", true},
+		{"standalone", pluginOptions{}, nil, "standalone", "web", false},
+		{"compose", pluginOptions{}, map[string]string{composeProjectLabel: "proj"}, "proj", "proj_web", true},
+		{
+			"stackBeforeCompose", pluginOptions{},
+			map[string]string{defaultStackLabel: "mystack", composeProjectLabel: "proj"}, "mystack", "mystack_web", true,
+		},
+		{
+			"customLabel", pluginOptions{StackLabel: "team.stack"},
+			map[string]string{"team.stack": "payments", defaultStackLabel: "mystack"}, "payments", "payments_web", true,
+		},
+		{
+			"customLabelMissing", pluginOptions{StackLabel: "team.stack"},
+			map[string]string{defaultStackLabel: "mystack"}, "standalone", "web", false,
+		},
+		{"customStandalone", pluginOptions{StandaloneStackName: "-"}, nil, "-", "web", false},
+		{"emptyLabel", pluginOptions{}, map[string]string{defaultStackLabel: ""}, "standalone", "web", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := &swarmPlugin{options: tt.options}
+
+			if stackName, stacked := p.resolveStack(tt.labels); stackName != tt.wantStack || stacked != tt.wantStacked {
+				t.Errorf("resolveStack() = %s, %v, want %s, %v", stackName, stacked, tt.wantStack, tt.wantStacked)
+			}
+
+			if stackName, key := p.resolveService("web", tt.labels); stackName != tt.wantStack || key != tt.wantKey {
+				t.Errorf("resolveService() = %s, %s, want %s, %s", stackName, key, tt.wantStack, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestStandaloneStackName(t *testing.T) {
+	t.Parallel()
+
+	f := newFakeDocker(t, "swarm")
+	f.addService(`{
+		"ID": "svcreal001",
+		"Spec": {
+			"Name": "api",
+			"Labels": {"com.docker.stack.namespace": "standalone"},
+			"Mode": {"Replicated": {"Replicas": 1}}
+		}
+	}`)
+
+	p := newTestPlugin(t, f)
+	p.options.StandaloneStackName = "(none)"
+
+	services, err := p.getStackServices("standalone")
+	if err != nil || len(services) != 1 || services[0].ID != "svcreal001" {
+		t.Fatalf("getStackServices(standalone) = %v, %v, want the api service only", services, err)
+	}
+
+	services, err = p.getStackServices("(none)")
+	if err != nil || len(services) != 1 || services[0].Spec.Name != "worker" {
+		t.Fatalf("getStackServices((none)) = %v, %v, want the worker service only", services, err)
+	}
+}
@@ -0,0 +1,802 @@
+package main
+
+import (
+	"crypto/md5" //nolint:gosec // used for stable identifiers, not for security
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.zabbix.com/sdk/errs"
+	"golang.zabbix.com/sdk/metric"
+)
+
+const (
+	templateName    = "Docker Swarm by Zabbix agent 2"
+	templateGroup   = "Templates/Applications"
+	templateVersion = "6.0"
+
+	valueTypeFloat    = "FLOAT"
+	valueTypeUnsigned = "UNSIGNED"
+	valueTypeText     = "TEXT"
+	valueTypeLog      = "LOG"
+
+	flagValueMap      = "Docker Swarm flag"
+	portStateValueMap = "Docker Swarm port state"
+	stackStatusMap    = "Docker Swarm stack status"
+
+	serviceKeyParams = "[{#SERVICE.KEY}]"
+	stackParams      = "[{#STACK.NAME}]"
+	stackModeParams  = "[{#STACK.NAME},{$SWARM.STACK.HEALTH.MODE}]"
+	networkParams    = "[{#NETWORK.NAME}]"
+	secretParams     = "[{#SECRET.NAME}]"
+	configParams     = "[{#CONFIG.NAME}]"
+	portParams       = "[{#SERVICE.KEY},{#PORT.PUBLISHED}]"
+	logErrorsParams  = `[{#SERVICE.KEY},"{$SWARM.LOG.ERRORS.REGEX}",5m]`
+	crashLoopParams  = "[{#SERVICE.KEY},{$SWARM.CRASHLOOP.FAILURES},{$SWARM.CRASHLOOP.WINDOW}]"
+)
+
+// templateItem is an item or item prototype of the template. Items with a metric key query the
+// plugin, the others are dependent items extracting a JSON field of their master item.
+type templateItem struct {
+	key       swarmMetricKey
+	params    string
+	name      string
+	valueType string
+	units     string
+	delay     string
+	valueMap  string
+
+	// dependentKey, description and jsonPath are set for dependent items only.
+	dependentKey string
+	description  string
+	jsonPath     string
+
+	dependents []templateItem
+}
+
+// templateTrigger is a trigger prototype of the template.
+type templateTrigger struct {
+	name        string
+	expression  string
+	priority    string
+	description string
+}
+
+// templateDiscovery is a discovery rule of the template with its prototypes.
+type templateDiscovery struct {
+	key    swarmMetricKey
+	params string
+	name   string
+	delay  string
+	// component is the value of the component tag of the prototypes.
+	component string
+	// prefix is prepended to the names of the item prototypes.
+	prefix string
+	// itemParams are the default parameters of the item prototypes and of their dependent items.
+	itemParams string
+	// filters maps LLD macros to the regular expressions they must match.
+	filters  map[string]string
+	items    []templateItem
+	triggers []templateTrigger
+}
+
+// templateMacros are the user macros of the template with their default values and descriptions.
+var templateMacros = [][3]string{
+	{"{$SWARM.SERVICE.NAME.MATCHES}", "", "Regular expression of service names to discover, all if empty"},
+	{"{$SWARM.SERVICE.NAME.NOT_MATCHES}", "", "Regular expression of service names to skip"},
+	{"{$SWARM.STACK.MATCHES}", "", "Regular expression of stack names to discover, all if empty"},
+	{"{$SWARM.STACK.NOT_MATCHES}", "", "Regular expression of stack names to skip"},
+	{"{$SWARM.STACK.HEALTH.MODE}", "all", "Stack health mode: all, any or a minimum percentage of running replicas"},
+	{"{$SWARM.CRASHLOOP.FAILURES}", "3", "Failed tasks within the crash loop window reported as a crash loop"},
+	{"{$SWARM.CRASHLOOP.WINDOW}", "10m", "Time window of the crash loop detection"},
+	{"{$SWARM.LOG.ERRORS.REGEX}", "(?i)error|fatal|panic", "Regular expression of service log lines counted as errors"},
+	{"{$SWARM.LOG.ERRORS.MAX.WARN}", "0", "Number of log errors within 5 minutes above which a problem is raised"},
+	{"{$SWARM.NETWORK.PUSED.MAX.WARN}", "80", "Percentage of used overlay network addresses raising a problem"},
+	{"{$SWARM.SECRET.AGE.MAX}", "90d", "Time since the last update of a secret after which rotation is due"},
+	{"{$SWARM.CERT.NAME.MATCHES}", "", "Regular expression of config names checked for certificates, all if empty"},
+	{"{$SWARM.CERT.EXPIRY.WARN}", "30d", "Time before certificate expiry when a problem is raised"},
+}
+
+// itemRef returns the reference to an item of the template used in trigger expressions.
+func itemRef(key, params string) string {
+	return "/" + templateName + "/" + key + params
+}
+
+// last returns the expression of the last value of an item of the template.
+func last(key, params string) string {
+	return "last(" + itemRef(key, params) + ")"
+}
+
+// templateLayout describes how the registered metrics are arranged in the template: the items of
+// the template itself and the discovery rules with their item and trigger prototypes.
+func templateLayout() ([]templateItem, []templateDiscovery) {
+	items := []templateItem{
+		{key: eventsLog, name: "Events log", valueType: valueTypeLog, delay: "1m"},
+	}
+
+	discoveries := []templateDiscovery{
+		{
+			key: serviceDiscoveryMetric,
+			params: "[{$SWARM.SERVICE.NAME.MATCHES},{$SWARM.SERVICE.NAME.NOT_MATCHES}," +
+				"{$SWARM.STACK.MATCHES},{$SWARM.STACK.NOT_MATCHES}]",
+			name:       "Services discovery",
+			delay:      "5m",
+			component:  "service",
+			prefix:     "Service {#SERVICE.KEY}: ",
+			itemParams: serviceKeyParams,
+			items: []templateItem{
+				{key: serviceReplicasDesired, name: "Replicas desired", valueType: valueTypeUnsigned, delay: "1m"},
+				{key: serviceReplicasRunning, name: "Replicas running", valueType: valueTypeUnsigned, delay: "1m"},
+				{key: serviceRestartCount, name: "Restarts", valueType: valueTypeUnsigned, delay: "1m"},
+				{key: serviceTaskCount, name: "Tasks", valueType: valueTypeUnsigned, delay: "5m"},
+				{
+					key: serviceLastRestart, name: "Last restart", valueType: valueTypeUnsigned, units: "unixtime",
+					delay: "1m",
+				},
+				{
+					key: serviceUptime, name: "Uptime", valueType: valueTypeText, delay: "1m",
+					dependents: []templateItem{{
+						dependentKey: "swarm.service.uptime.min", name: "Uptime of the youngest task",
+						valueType: valueTypeUnsigned, units: "uptime", jsonPath: "$.min",
+						description: "Age of the most recently started running task.",
+					}},
+				},
+				{
+					key: serviceRecreated, name: "Re-creations", valueType: valueTypeText, delay: "1m",
+					dependents: []templateItem{{
+						dependentKey: "swarm.service.recreated.count", name: "Re-creation count",
+						valueType: valueTypeUnsigned, jsonPath: "$.count",
+						description: "Number of times the service was removed and deployed again since the agent started.",
+					}},
+				},
+				{
+					key: serviceMaintenance, name: "Maintenance", valueType: valueTypeUnsigned, delay: "1m",
+					valueMap: flagValueMap,
+				},
+				{
+					key: serviceEvents, name: "Events", valueType: valueTypeText, delay: "5m",
+					dependents: []templateItem{{
+						dependentKey: "swarm.service.events.count", name: "Events per hour",
+						valueType: valueTypeUnsigned, jsonPath: "$.count",
+						description: "Number of service and container events within the last hour.",
+					}},
+				},
+				{
+					key: serviceLogErrors, params: logErrorsParams, name: "Log errors", valueType: valueTypeUnsigned,
+					delay: "5m",
+				},
+				{
+					key: serviceCrashLoop, params: crashLoopParams, name: "Crash loop", valueType: valueTypeText,
+					delay: "1m",
+					dependents: []templateItem{{
+						dependentKey: "swarm.service.crashloop.state", name: "Crash loop state",
+						valueType: valueTypeUnsigned, valueMap: flagValueMap, jsonPath: "$.crashloop",
+						description: "Whether the tasks of the service keep failing.",
+					}},
+				},
+			},
+			triggers: []templateTrigger{
+				{
+					name: "Service {#SERVICE.KEY}: Running replicas below desired",
+					expression: last(string(serviceReplicasRunning), serviceKeyParams) + "<" +
+						last(string(serviceReplicasDesired), serviceKeyParams) + " and " +
+						last(string(serviceMaintenance), serviceKeyParams) + "=0",
+					priority: "WARNING",
+				},
+				{
+					name:       "Service {#SERVICE.KEY}: Has been restarted",
+					expression: "change(" + itemRef(string(serviceRestartCount), serviceKeyParams) + ")>0",
+					priority:   "INFO",
+				},
+				{
+					name:       "Service {#SERVICE.KEY}: Has been re-created",
+					expression: "change(" + itemRef("swarm.service.recreated.count", serviceKeyParams) + ")>0",
+					priority:   "INFO",
+				},
+				{
+					name:        "Service {#SERVICE.KEY}: Crash loop",
+					expression:  last("swarm.service.crashloop.state", serviceKeyParams) + "=1",
+					priority:    "HIGH",
+					description: "Tasks of the service keep failing and are being rescheduled.",
+				},
+				{
+					name:       "Service {#SERVICE.KEY}: Errors in the logs",
+					expression: last(string(serviceLogErrors), logErrorsParams) + ">{$SWARM.LOG.ERRORS.MAX.WARN}",
+					priority:   "WARNING",
+				},
+			},
+		},
+		{
+			key:        stackDiscoveryMetric,
+			name:       "Stacks discovery",
+			delay:      "10m",
+			component:  "stack",
+			prefix:     "Stack {#STACK.NAME}: ",
+			itemParams: stackParams,
+			items: []templateItem{
+				{
+					key: stackHealthMetric, params: stackModeParams, name: "Health", valueType: valueTypeText,
+					delay: "1m",
+					dependents: []templateItem{
+						{
+							dependentKey: "swarm.stack.health.percentage", name: "Health percentage",
+							valueType: valueTypeFloat, units: "%", jsonPath: "$.health_percentage",
+							description: "Weighted percentage of healthy services.",
+						},
+						{
+							dependentKey: "swarm.stack.health.unhealthy", name: "Unhealthy services",
+							valueType: valueTypeUnsigned, jsonPath: "$.unhealthy_services",
+							description: "Number of degraded and down services, unknown services are not counted.",
+						},
+					},
+				},
+				{
+					key: stackStatusMetric, params: stackModeParams, name: "Status", valueType: valueTypeUnsigned,
+					delay: "1m", valueMap: stackStatusMap,
+				},
+				{key: stackReplicasMetric, name: "Replicas", valueType: valueTypeText, delay: "1m"},
+				{key: stackRestartsMetric, name: "Restarts", valueType: valueTypeUnsigned, delay: "1m"},
+				{
+					key: stackLastDeployMetric, name: "Last deploy", valueType: valueTypeUnsigned, units: "unixtime",
+					delay: "5m",
+				},
+			},
+			triggers: []templateTrigger{
+				{
+					name:       "Stack {#STACK.NAME}: Critical",
+					expression: last(string(stackStatusMetric), stackModeParams) + "=" + strconv.Itoa(stackStatusCritical),
+					priority:   "HIGH",
+				},
+				{
+					name:       "Stack {#STACK.NAME}: Degraded",
+					expression: last(string(stackStatusMetric), stackModeParams) + "=" + strconv.Itoa(stackStatusDegraded),
+					priority:   "WARNING",
+				},
+				{
+					name:       "Stack {#STACK.NAME}: Has been deployed",
+					expression: "change(" + itemRef(string(stackLastDeployMetric), stackParams) + ")>0",
+					priority:   "INFO",
+				},
+			},
+		},
+		{
+			key:        networkDiscoveryMetric,
+			name:       "Overlay networks discovery",
+			delay:      "1h",
+			component:  "network",
+			prefix:     "Network {#NETWORK.NAME}: ",
+			itemParams: networkParams,
+			items: []templateItem{
+				{key: networkAddresses, name: "Addresses", valueType: valueTypeText, delay: "5m"},
+				{
+					key: networkAddressesPUsed, name: "Addresses used, in %", valueType: valueTypeFloat, units: "%",
+					delay: "5m",
+				},
+			},
+			triggers: []templateTrigger{
+				{
+					name: "Network {#NETWORK.NAME}: Running out of addresses",
+					expression: "min(" + itemRef(string(networkAddressesPUsed), networkParams) +
+						",15m)>{$SWARM.NETWORK.PUSED.MAX.WARN}",
+					priority: "WARNING",
+				},
+			},
+		},
+		{
+			key:        secretDiscoveryMetric,
+			name:       "Secrets discovery",
+			delay:      "1h",
+			component:  "secret",
+			prefix:     "Secret {#SECRET.NAME}: ",
+			itemParams: secretParams,
+			items:      swarmObjectItems(secretAge, secretUpdateAge, secretServices),
+			triggers: []templateTrigger{
+				{
+					name:       "Secret {#SECRET.NAME}: Rotation is due",
+					expression: last(string(secretUpdateAge), secretParams) + ">{$SWARM.SECRET.AGE.MAX}",
+					priority:   "INFO",
+				},
+				{
+					name:       "Secret {#SECRET.NAME}: Not used by any service",
+					expression: last(string(secretServices), secretParams) + "=0",
+					priority:   "INFO",
+				},
+			},
+		},
+		{
+			key:        configDiscoveryMetric,
+			name:       "Configs discovery",
+			delay:      "1h",
+			component:  "config",
+			prefix:     "Config {#CONFIG.NAME}: ",
+			itemParams: configParams,
+			items:      swarmObjectItems(configAge, configUpdateAge, configServices),
+		},
+		{
+			key:        certDiscoveryMetric,
+			params:     "[{$SWARM.CERT.NAME.MATCHES}]",
+			name:       "Certificates discovery",
+			delay:      "1h",
+			component:  "certificate",
+			prefix:     "Config {#CONFIG.NAME}: ",
+			itemParams: configParams,
+			items: []templateItem{
+				{
+					key: configCertExpiry, name: "Certificate expiry", valueType: valueTypeUnsigned, units: "unixtime",
+					delay: "1h",
+				},
+			},
+			triggers: []templateTrigger{
+				{
+					name:       "Config {#CONFIG.NAME}: Certificate expires soon",
+					expression: last(string(configCertExpiry), configParams) + "-now()<{$SWARM.CERT.EXPIRY.WARN}",
+					priority:   "WARNING",
+				},
+			},
+		},
+		{
+			key:        portDiscoveryMetric,
+			name:       "Published ports discovery",
+			delay:      "5m",
+			component:  "port",
+			prefix:     "Service {#SERVICE.KEY}: ",
+			itemParams: portParams,
+			// Only TCP ports can be checked, and host mode ports are only reachable on the nodes
+			// running a task of the service
+			filters: map[string]string{"{#PORT.PROTOCOL}": "^tcp$", "{#PORT.MODE}": "^ingress$"},
+			items: []templateItem{
+				{
+					key: servicePortCheck, name: "Port {#PORT.PUBLISHED} state", valueType: valueTypeUnsigned,
+					delay: "1m", valueMap: portStateValueMap,
+				},
+			},
+			triggers: []templateTrigger{
+				{
+					name:       "Service {#SERVICE.KEY}: Port {#PORT.PUBLISHED} is not reachable",
+					expression: "max(" + itemRef(string(servicePortCheck), portParams) + ",3m)=0",
+					priority:   "HIGH",
+				},
+			},
+		},
+	}
+
+	return items, discoveries
+}
+
+// signVanishableItems makes the unsigned item prototypes of vanishable metrics float, as the
+// negative vanished service policy returns -1, which unsigned items reject.
+func signVanishableItems(metrics map[swarmMetricKey]*swarmMetric, discoveries []templateDiscovery) {
+	for i := range discoveries {
+		for j, item := range discoveries[i].items {
+			if m, ok := metrics[item.key]; ok && m.vanishable && item.valueType == valueTypeUnsigned {
+				discoveries[i].items[j].valueType = valueTypeFloat
+			}
+		}
+	}
+}
+
+// swarmObjectItems returns the item prototypes shared by secrets and configs.
+func swarmObjectItems(age, updateAge, services swarmMetricKey) []templateItem {
+	return []templateItem{
+		{key: age, name: "Age", valueType: valueTypeUnsigned, units: "uptime", delay: "1h"},
+		{key: updateAge, name: "Time since update", valueType: valueTypeUnsigned, units: "uptime", delay: "1h"},
+		{key: services, name: "Services", valueType: valueTypeUnsigned, delay: "1h"},
+	}
+}
+
+// writeTemplate writes a Zabbix template in YAML import format covering all registered metrics.
+func (p *swarmPlugin) writeTemplate(w io.Writer) error {
+	export, err := p.template()
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+
+	encodeYAML(&b, reflect.ValueOf(export), 0)
+
+	_, err = io.WriteString(w, b.String())
+	if err != nil {
+		return errs.Wrap(err, "cannot write template")
+	}
+
+	return nil
+}
+
+// template returns the Zabbix template of the registered metrics, with the metric descriptions
+// from the registry as item descriptions.
+func (p *swarmPlugin) template() (zbxFile, error) {
+	metricSet := metric.MetricSet{}
+
+	for k, m := range p.metrics {
+		metricSet[string(k)] = m.metric
+	}
+
+	descriptions := map[swarmMetricKey]string{}
+
+	list := metricSet.List()
+	for i := 0; i+1 < len(list); i += 2 {
+		descriptions[swarmMetricKey(list[i])] = list[i+1]
+	}
+
+	items, discoveries := templateLayout()
+
+	err := checkTemplateLayout(p.metrics, items, discoveries)
+	if err != nil {
+		return zbxFile{}, err
+	}
+
+	if p.options.VanishedServicePolicy == vanishedPolicyNegative {
+		signVanishableItems(p.metrics, discoveries)
+	}
+
+	template := zbxTemplate{
+		UUID:     templateUUID("template", templateName),
+		Template: templateName,
+		Name:     templateName,
+		Description: fmt.Sprintf("Monitors Docker Swarm services, stacks, networks, secrets, configs and "+
+			"published ports with the %s plugin of Zabbix agent 2.\n\nGenerated by the plugin version %d.%d.%d%s.",
+			Name, PLUGIN_VERSION_MAJOR, PLUGIN_VERSION_MINOR, PLUGIN_VERSION_PATCH, PLUGIN_VERSION_RC),
+		Groups:    []zbxGroup{{Name: templateGroup}},
+		ValueMaps: templateValueMaps(),
+	}
+
+	for _, item := range items {
+		template.Items = append(template.Items, item.export(descriptions, "events", "", "")...)
+	}
+
+	for _, d := range discoveries {
+		template.DiscoveryRules = append(template.DiscoveryRules, d.export(descriptions))
+	}
+
+	for _, m := range templateMacros {
+		template.Macros = append(template.Macros, zbxMacro{Macro: m[0], Value: m[1], Description: m[2]})
+	}
+
+	return zbxFile{Export: zbxExport{
+		Version:   templateVersion,
+		Groups:    []zbxGroup{{UUID: templateUUID("group", templateGroup), Name: templateGroup}},
+		Templates: []zbxTemplate{template},
+	}}, nil
+}
+
+// checkTemplateLayout verifies that every registered metric appears in the template layout exactly
+// once and that the layout does not refer to unregistered metrics.
+func checkTemplateLayout(
+	metrics map[swarmMetricKey]*swarmMetric, items []templateItem, discoveries []templateDiscovery,
+) error {
+	count := map[swarmMetricKey]int{}
+
+	for _, item := range items {
+		count[item.key]++
+	}
+
+	for _, d := range discoveries {
+		count[d.key]++
+
+		for _, item := range d.items {
+			count[item.key]++
+		}
+	}
+
+	for key, n := range count {
+		if _, ok := metrics[key]; !ok {
+			return errs.New("template refers to unknown metric " + string(key))
+		}
+
+		if n > 1 {
+			return errs.New("metric " + string(key) + " appears more than once in the template")
+		}
+	}
+
+	for key := range metrics {
+		if count[key] == 0 {
+			return errs.New("metric " + string(key) + " is missing in the template")
+		}
+	}
+
+	return nil
+}
+
+// export returns the item and its dependent items in the template export format. Dependent items
+// get params as key parameters, since they only identify the discovered object.
+func (item templateItem) export(descriptions map[swarmMetricKey]string, component, prefix, params string) []zbxItem {
+	result := zbxItem{
+		Name:        prefix + item.name,
+		Delay:       item.delay,
+		ValueType:   item.valueType,
+		Units:       item.units,
+		Description: item.description,
+		Tags:        []zbxTag{{Tag: "component", Value: component}},
+	}
+
+	if item.key != "" {
+		if item.params == "" {
+			item.params = params
+		}
+
+		result.Key = string(item.key) + item.params
+		result.Description = descriptions[item.key]
+	} else {
+		result.Key = item.dependentKey + params
+		result.Type = "DEPENDENT"
+		result.Delay = "0"
+		result.Preprocessing = []zbxPreprocessing{{Type: "JSONPATH", Parameters: []string{item.jsonPath}}}
+	}
+
+	result.UUID = templateUUID("item", result.Key)
+
+	if item.valueType == valueTypeText || item.valueType == valueTypeLog {
+		result.History = "7d"
+		result.Trends = "0"
+	}
+
+	if item.valueMap != "" {
+		result.ValueMap = &zbxName{Name: item.valueMap}
+	}
+
+	items := []zbxItem{result}
+
+	for _, dependent := range item.dependents {
+		exported := dependent.export(descriptions, component, prefix, params)
+		exported[0].MasterItem = &zbxKey{Key: result.Key}
+		items = append(items, exported...)
+	}
+
+	return items
+}
+
+// export returns the discovery rule with its prototypes in the template export format.
+func (d templateDiscovery) export(descriptions map[swarmMetricKey]string) zbxDiscoveryRule {
+	rule := zbxDiscoveryRule{
+		UUID:        templateUUID("discovery", string(d.key)),
+		Name:        d.name,
+		Key:         string(d.key) + d.params,
+		Delay:       d.delay,
+		Description: descriptions[d.key],
+	}
+
+	if len(d.filters) > 0 {
+		macros := make([]string, 0, len(d.filters))
+		for macro := range d.filters {
+			macros = append(macros, macro)
+		}
+
+		sort.Strings(macros)
+
+		rule.Filter = &zbxFilter{EvalType: "AND"}
+		for i, macro := range macros {
+			rule.Filter.Conditions = append(rule.Filter.Conditions, zbxCondition{
+				Macro:     macro,
+				Value:     d.filters[macro],
+				FormulaID: string(rune('A' + i)),
+			})
+		}
+	}
+
+	for _, item := range d.items {
+		rule.ItemPrototypes = append(rule.ItemPrototypes, item.export(descriptions, d.component, d.prefix, d.itemParams)...)
+	}
+
+	for _, t := range d.triggers {
+		rule.TriggerPrototypes = append(rule.TriggerPrototypes, zbxTrigger{
+			UUID:        templateUUID("trigger", t.name),
+			Expression:  t.expression,
+			Name:        t.name,
+			Priority:    t.priority,
+			Description: t.description,
+		})
+	}
+
+	return rule
+}
+
+// templateValueMaps returns the value maps of the template.
+func templateValueMaps() []zbxValueMap {
+	statuses := make([]int, 0, len(stackStatusNames))
+	for status := range stackStatusNames {
+		statuses = append(statuses, status)
+	}
+
+	sort.Ints(statuses)
+
+	stackStatuses := zbxValueMap{UUID: templateUUID("valuemap", stackStatusMap), Name: stackStatusMap}
+	for _, status := range statuses {
+		stackStatuses.Mappings = append(stackStatuses.Mappings, zbxMapping{
+			Value:    strconv.Itoa(status),
+			NewValue: stackStatusNames[status],
+		})
+	}
+
+	return []zbxValueMap{
+		{
+			UUID:     templateUUID("valuemap", flagValueMap),
+			Name:     flagValueMap,
+			Mappings: []zbxMapping{{Value: "0", NewValue: "No"}, {Value: "1", NewValue: "Yes"}},
+		},
+		{
+			UUID:     templateUUID("valuemap", portStateValueMap),
+			Name:     portStateValueMap,
+			Mappings: []zbxMapping{{Value: "0", NewValue: "Down"}, {Value: "1", NewValue: "Up"}},
+		},
+		stackStatuses,
+	}
+}
+
+// templateUUID returns a stable UUIDv4 formatted identifier for a template entity, so an imported
+// template of a newer release updates the existing entities instead of creating new ones.
+func templateUUID(kind, name string) string {
+	sum := md5.Sum([]byte(templateName + "/" + kind + "/" + name)) //nolint:gosec // see import
+
+	sum[6] = sum[6]&0x0f | 0x40
+	sum[8] = sum[8]&0x3f | 0x80
+
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeYAML writes a struct in YAML block style, using the yaml tags of its fields as keys. Only
+// string, []string, pointer to struct, struct and []struct fields are supported. Empty fields are
+// omitted and strings are quoted.
+func encodeYAML(b *strings.Builder, v reflect.Value, indent int) {
+	pad := strings.Repeat(" ", indent)
+
+	for i := 0; i < v.NumField(); i++ {
+		key := v.Type().Field(i).Tag.Get("yaml")
+		field := v.Field(i)
+
+		if field.IsZero() || (field.Kind() == reflect.Slice && field.Len() == 0) {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			b.WriteString(pad + key + ": " + yamlQuote(field.String()) + "\n")
+		case reflect.Pointer:
+			b.WriteString(pad + key + ":\n")
+			encodeYAML(b, field.Elem(), indent+2)
+		case reflect.Struct:
+			b.WriteString(pad + key + ":\n")
+			encodeYAML(b, field, indent+2)
+		case reflect.Slice:
+			b.WriteString(pad + key + ":\n")
+
+			for j := 0; j < field.Len(); j++ {
+				element := field.Index(j)
+				if element.Kind() == reflect.String {
+					b.WriteString(pad + "  - " + yamlQuote(element.String()) + "\n")
+
+					continue
+				}
+
+				// The first field of a mapping follows the dash of its sequence entry
+				var e strings.Builder
+
+				encodeYAML(&e, element, indent+4)
+				b.WriteString(pad + "  - " + e.String()[indent+4:])
+			}
+		}
+	}
+}
+
+// yamlQuote returns s as a single quoted YAML scalar, or double quoted if it contains line breaks.
+func yamlQuote(s string) string {
+	if strings.ContainsAny(s, "\n\r") {
+		return strconv.Quote(s)
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// zbxFile is the Zabbix configuration export file.
+type zbxFile struct {
+	Export zbxExport `yaml:"zabbix_export"`
+}
+
+type zbxExport struct {
+	Version   string        `yaml:"version"`
+	Groups    []zbxGroup    `yaml:"groups"`
+	Templates []zbxTemplate `yaml:"templates"`
+}
+
+type zbxGroup struct {
+	UUID string `yaml:"uuid"`
+	Name string `yaml:"name"`
+}
+
+type zbxTemplate struct {
+	UUID           string             `yaml:"uuid"`
+	Template       string             `yaml:"template"`
+	Name           string             `yaml:"name"`
+	Description    string             `yaml:"description"`
+	Groups         []zbxGroup         `yaml:"groups"`
+	Items          []zbxItem          `yaml:"items"`
+	DiscoveryRules []zbxDiscoveryRule `yaml:"discovery_rules"`
+	Macros         []zbxMacro         `yaml:"macros"`
+	ValueMaps      []zbxValueMap      `yaml:"valuemaps"`
+}
+
+type zbxItem struct {
+	UUID          string             `yaml:"uuid"`
+	Name          string             `yaml:"name"`
+	Type          string             `yaml:"type"`
+	Key           string             `yaml:"key"`
+	Delay         string             `yaml:"delay"`
+	History       string             `yaml:"history"`
+	Trends        string             `yaml:"trends"`
+	ValueType     string             `yaml:"value_type"`
+	Units         string             `yaml:"units"`
+	Description   string             `yaml:"description"`
+	ValueMap      *zbxName           `yaml:"valuemap"`
+	Preprocessing []zbxPreprocessing `yaml:"preprocessing"`
+	MasterItem    *zbxKey            `yaml:"master_item"`
+	Tags          []zbxTag           `yaml:"tags"`
+}
+
+type zbxName struct {
+	Name string `yaml:"name"`
+}
+
+type zbxKey struct {
+	Key string `yaml:"key"`
+}
+
+type zbxPreprocessing struct {
+	Type       string   `yaml:"type"`
+	Parameters []string `yaml:"parameters"`
+}
+
+type zbxTag struct {
+	Tag   string `yaml:"tag"`
+	Value string `yaml:"value"`
+}
+
+type zbxDiscoveryRule struct {
+	UUID              string       `yaml:"uuid"`
+	Name              string       `yaml:"name"`
+	Key               string       `yaml:"key"`
+	Delay             string       `yaml:"delay"`
+	Description       string       `yaml:"description"`
+	Filter            *zbxFilter   `yaml:"filter"`
+	ItemPrototypes    []zbxItem    `yaml:"item_prototypes"`
+	TriggerPrototypes []zbxTrigger `yaml:"trigger_prototypes"`
+}
+
+type zbxFilter struct {
+	EvalType   string         `yaml:"evaltype"`
+	Conditions []zbxCondition `yaml:"conditions"`
+}
+
+type zbxCondition struct {
+	Macro     string `yaml:"macro"`
+	Value     string `yaml:"value"`
+	FormulaID string `yaml:"formulaid"`
+}
+
+type zbxTrigger struct {
+	UUID        string `yaml:"uuid"`
+	Expression  string `yaml:"expression"`
+	Name        string `yaml:"name"`
+	Priority    string `yaml:"priority"`
+	Description string `yaml:"description"`
+}
+
+type zbxMacro struct {
+	Macro       string `yaml:"macro"`
+	Value       string `yaml:"value"`
+	Description string `yaml:"description"`
+}
+
+type zbxValueMap struct {
+	UUID     string       `yaml:"uuid"`
+	Name     string       `yaml:"name"`
+	Mappings []zbxMapping `yaml:"mappings"`
+}
+
+type zbxMapping struct {
+	Value    string `yaml:"value"`
+	NewValue string `yaml:"newvalue"`
+}
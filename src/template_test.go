@@ -0,0 +1,217 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	t.Parallel()
+
+	p := newCLIPlugin(defaultSocketPath)
+
+	export, err := p.template()
+	if err != nil {
+		t.Fatalf("template() error = %v", err)
+	}
+
+	template := export.Export.Templates[0]
+
+	keys := map[string]bool{}
+	uuids := map[string]bool{}
+
+	addItems := func(items []zbxItem) {
+		for _, item := range items {
+			if keys[item.Key] {
+				t.Errorf("template() has duplicate item key %s", item.Key)
+			}
+
+			keys[item.Key] = true
+
+			if item.Description == "" {
+				t.Errorf("template() item %s has no description", item.Key)
+			}
+
+			if item.Type == "DEPENDENT" && (item.MasterItem == nil || !keys[item.MasterItem.Key]) {
+				t.Errorf("template() dependent item %s has no master item", item.Key)
+			}
+		}
+	}
+
+	addItems(template.Items)
+
+	var triggers []zbxTrigger
+
+	for _, rule := range template.DiscoveryRules {
+		addItems(rule.ItemPrototypes)
+		triggers = append(triggers, rule.TriggerPrototypes...)
+	}
+
+	// Trigger expressions must only refer to items of the template
+	ref := regexp.MustCompile("/" + regexp.QuoteMeta(templateName) + `/([a-z0-9_.]+(\[[^\]]*\])?)`)
+
+	for _, trigger := range triggers {
+		uuids[trigger.UUID] = true
+
+		refs := ref.FindAllStringSubmatch(trigger.Expression, -1)
+		if len(refs) == 0 {
+			t.Errorf("template() trigger %q refers to no item", trigger.Name)
+		}
+
+		for _, r := range refs {
+			if !keys[r[1]] {
+				t.Errorf("template() trigger %q refers to unknown item %s", trigger.Name, r[1])
+			}
+		}
+	}
+
+	if len(uuids) != len(triggers) {
+		t.Errorf("template() has %d triggers with %d distinct UUIDs", len(triggers), len(uuids))
+	}
+
+	// Macros used in keys and expressions must be defined
+	defined := map[string]bool{}
+	for _, m := range template.Macros {
+		defined[m.Macro] = true
+	}
+
+	var used strings.Builder
+
+	for _, rule := range template.DiscoveryRules {
+		used.WriteString(rule.Key)
+	}
+
+	for key := range keys {
+		used.WriteString(key)
+	}
+
+	for _, trigger := range triggers {
+		used.WriteString(trigger.Expression)
+	}
+
+	for _, macro := range regexp.MustCompile(`\{\$[A-Z0-9_.]+\}`).FindAllString(used.String(), -1) {
+		if !defined[macro] {
+			t.Errorf("template() uses undefined macro %s", macro)
+		}
+	}
+}
+
+func TestCheckTemplateLayout(t *testing.T) {
+	t.Parallel()
+
+	p := newCLIPlugin(defaultSocketPath)
+	items, discoveries := templateLayout()
+
+	delete(p.metrics, serviceUptime)
+
+	err := checkTemplateLayout(p.metrics, items, discoveries)
+	if err == nil || !strings.Contains(err.Error(), "unknown metric swarm.service.uptime") {
+		t.Fatalf("checkTemplateLayout() with unregistered metric error = %v", err)
+	}
+
+	p = newCLIPlugin(defaultSocketPath)
+	p.metrics["swarm.new"] = &swarmMetric{}
+
+	err = checkTemplateLayout(p.metrics, items, discoveries)
+	if err == nil || !strings.Contains(err.Error(), "swarm.new is missing") {
+		t.Fatalf("checkTemplateLayout() with metric missing in the template error = %v", err)
+	}
+}
+
+func TestWriteTemplate(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+
+	err := newCLIPlugin(defaultSocketPath).writeTemplate(&b)
+	if err != nil {
+		t.Fatalf("writeTemplate() error = %v", err)
+	}
+
+	want := "zabbix_export:\n  version: '6.0'\n  groups:\n    - uuid: "
+	if !strings.HasPrefix(b.String(), want) {
+		t.Fatalf("writeTemplate() = %q..., want prefix %q", b.String()[:len(want)], want)
+	}
+
+	if got := yamlQuote("it's"); got != "'it''s'" {
+		t.Errorf("yamlQuote() = %s, want 'it''s'", got)
+	}
+
+	if got := yamlQuote("a\nb"); got != `"a\nb"` {
+		t.Errorf("yamlQuote() = %s, want \"a\\nb\"", got)
+	}
+}
+
+func TestTemplateVanishableItemsSigned(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{vanishedPolicyError, valueTypeUnsigned},
+		{vanishedPolicyNegative, valueTypeFloat},
+		{vanishedPolicyLast, valueTypeUnsigned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			t.Parallel()
+
+			p := newCLIPlugin(defaultSocketPath)
+			p.options.VanishedServicePolicy = tt.policy
+
+			export, err := p.template()
+			if err != nil {
+				t.Fatalf("template() error = %v", err)
+			}
+
+			// Only the negative policy returns -1, which unsigned items reject
+			for _, rule := range export.Export.Templates[0].DiscoveryRules {
+				for _, item := range rule.ItemPrototypes {
+					key, _, _ := strings.Cut(item.Key, "[")
+
+					m, ok := p.metrics[swarmMetricKey(key)]
+					if ok && m.vanishable && item.ValueType != tt.want {
+						t.Errorf("template() item %s has value type %s, want %s", item.Key, item.ValueType, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTemplatePortDiscoveryIngressOnly(t *testing.T) {
+	t.Parallel()
+
+	p := newCLIPlugin(defaultSocketPath)
+
+	export, err := p.template()
+	if err != nil {
+		t.Fatalf("template() error = %v", err)
+	}
+
+	for _, rule := range export.Export.Templates[0].DiscoveryRules {
+		if !strings.HasPrefix(rule.Key, string(portDiscoveryMetric)) {
+			continue
+		}
+
+		if rule.Filter == nil {
+			t.Fatalf("template() port discovery has no filter")
+		}
+
+		conditions := map[string]string{}
+		for _, c := range rule.Filter.Conditions {
+			conditions[c.Macro] = c.Value
+		}
+
+		if conditions["{#PORT.MODE}"] != "^ingress$" || conditions["{#PORT.PROTOCOL}"] != "^tcp$" {
+			t.Errorf("template() port discovery filter = %v, want ingress TCP ports", conditions)
+		}
+
+		return
+	}
+
+	t.Errorf("template() has no port discovery")
+}
@@ -2,15 +2,63 @@ package main
 
 // Service represents a Docker Swarm service.
 type Service struct {
-	ID   string      `json:"ID"`
-	Spec ServiceSpec `json:"Spec"`
+	ID        string          `json:"ID"`
+	CreatedAt string          `json:"CreatedAt"`
+	UpdatedAt string          `json:"UpdatedAt"`
+	Spec      ServiceSpec     `json:"Spec"`
+	Endpoint  ServiceEndpoint `json:"Endpoint"`
+}
+
+// ServiceEndpoint represents the endpoint of a service as allocated by the swarm.
+type ServiceEndpoint struct {
+	Ports      []PortConfig        `json:"Ports"`
+	VirtualIPs []EndpointVirtualIP `json:"VirtualIPs"`
+}
+
+// PortConfig represents a port published by a service.
+type PortConfig struct {
+	Protocol      string `json:"Protocol"`
+	TargetPort    uint32 `json:"TargetPort"`
+	PublishedPort uint32 `json:"PublishedPort"`
+	PublishMode   string `json:"PublishMode"`
+}
+
+// EndpointVirtualIP represents the virtual IP address of a service on a network.
+type EndpointVirtualIP struct {
+	NetworkID string `json:"NetworkID"`
+	Addr      string `json:"Addr"`
 }
 
 // ServiceSpec represents the specification of a service.
 type ServiceSpec struct {
-	Name   string            `json:"Name"`
-	Mode   ServiceMode       `json:"Mode"`
-	Labels map[string]string `json:"Labels"`
+	Name         string            `json:"Name"`
+	Mode         ServiceMode       `json:"Mode"`
+	Labels       map[string]string `json:"Labels"`
+	TaskTemplate TaskSpec          `json:"TaskTemplate"`
+}
+
+// TaskSpec represents the task template of a service.
+type TaskSpec struct {
+	ContainerSpec ContainerSpec `json:"ContainerSpec"`
+}
+
+// ContainerSpec represents the container specification of a service task template.
+type ContainerSpec struct {
+	TTY     bool              `json:"TTY"`
+	Secrets []SecretReference `json:"Secrets"`
+	Configs []ConfigReference `json:"Configs"`
+}
+
+// SecretReference is a reference from a service to a secret.
+type SecretReference struct {
+	SecretID   string `json:"SecretID"`
+	SecretName string `json:"SecretName"`
+}
+
+// ConfigReference is a reference from a service to a config.
+type ConfigReference struct {
+	ConfigID   string `json:"ConfigID"`
+	ConfigName string `json:"ConfigName"`
 }
 
 // ServiceMode represents the mode of a service (replicated or global).
@@ -29,16 +77,32 @@ type GlobalService struct{}
 
 // Task represents a task running as part of a service.
 type Task struct {
-	ID           string     `json:"ID"`
-	ServiceID    string     `json:"ServiceID"`
-	Status       TaskStatus `json:"Status"`
-	DesiredState string     `json:"DesiredState"`
+	ID                  string              `json:"ID"`
+	CreatedAt           string              `json:"CreatedAt"`
+	ServiceID           string              `json:"ServiceID"`
+	NodeID              string              `json:"NodeID"`
+	Status              TaskStatus          `json:"Status"`
+	DesiredState        string              `json:"DesiredState"`
+	NetworksAttachments []NetworkAttachment `json:"NetworksAttachments"`
+}
+
+// NetworkAttachment represents the addresses of a task on a network.
+type NetworkAttachment struct {
+	Network   NetworkAttachmentNetwork `json:"Network"`
+	Addresses []string                 `json:"Addresses"`
+}
+
+// NetworkAttachmentNetwork identifies the network of a network attachment.
+type NetworkAttachmentNetwork struct {
+	ID string `json:"ID"`
 }
 
 // TaskStatus represents the status of a task.
 type TaskStatus struct {
 	State           string               `json:"State"`
 	Timestamp       string               `json:"Timestamp"`
+	Message         string               `json:"Message"`
+	Err             string               `json:"Err"`
 	ContainerStatus *TaskContainerStatus `json:"ContainerStatus,omitempty"`
 }
 
@@ -48,13 +112,225 @@ type TaskContainerStatus struct {
 	ExitCode    int    `json:"ExitCode"`
 }
 
-// StackHealth represents the health status of a Docker Compose stack.
+// Container represents a container in the Docker container list.
+type Container struct {
+	ID      string            `json:"Id"`
+	Created int64             `json:"Created"`
+	Labels  map[string]string `json:"Labels"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+}
+
+// Info represents the system information of the Docker daemon.
+type Info struct {
+	Swarm SwarmInfo `json:"Swarm"`
+}
+
+// SwarmInfo represents the swarm membership of the Docker daemon.
+type SwarmInfo struct {
+	LocalNodeState string `json:"LocalNodeState"`
+}
+
+// SwarmObject represents a Docker Swarm secret or config.
+type SwarmObject struct {
+	ID        string          `json:"ID"`
+	CreatedAt string          `json:"CreatedAt"`
+	UpdatedAt string          `json:"UpdatedAt"`
+	Spec      SwarmObjectSpec `json:"Spec"`
+}
+
+// SwarmObjectSpec represents the specification of a secret or config.
+type SwarmObjectSpec struct {
+	Name   string            `json:"Name"`
+	Labels map[string]string `json:"Labels"`
+	// Data is only returned for configs, secret data is never exposed by the Docker API.
+	Data []byte `json:"Data,omitempty"`
+}
+
+// Network represents a Docker network.
+type Network struct {
+	ID      string      `json:"Id"`
+	Name    string      `json:"Name"`
+	Driver  string      `json:"Driver"`
+	Scope   string      `json:"Scope"`
+	Ingress bool        `json:"Ingress"`
+	IPAM    NetworkIPAM `json:"IPAM"`
+}
+
+// NetworkIPAM represents the IP address management configuration of a network.
+type NetworkIPAM struct {
+	Config []IPAMConfig `json:"Config"`
+}
+
+// IPAMConfig represents a subnet of a network.
+type IPAMConfig struct {
+	Subnet  string `json:"Subnet"`
+	Gateway string `json:"Gateway"`
+}
+
+// NetworkAddresses represents the address usage of an overlay network.
+type NetworkAddresses struct {
+	Total       int     `json:"total"`
+	Used        int     `json:"used"`
+	Free        int     `json:"free"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// LLDStack represents a stack in the stack discovery.
+type LLDStack struct {
+	StackName string `json:"{#STACK.NAME}"`
+}
+
+// LLDNetwork represents an overlay network in the network discovery.
+type LLDNetwork struct {
+	ID      string `json:"{#NETWORK.ID}"`
+	Name    string `json:"{#NETWORK.NAME}"`
+	Scope   string `json:"{#NETWORK.SCOPE}"`
+	Ingress bool   `json:"{#NETWORK.INGRESS}"`
+}
+
+// LLDPort represents a published port of a service in the port discovery.
+type LLDPort struct {
+	ServiceName string `json:"{#SERVICE.NAME}"`
+	ServiceKey  string `json:"{#SERVICE.KEY}"`
+	StackName   string `json:"{#STACK.NAME}"`
+	Target      uint32 `json:"{#PORT.TARGET}"`
+	Published   uint32 `json:"{#PORT.PUBLISHED}"`
+	Protocol    string `json:"{#PORT.PROTOCOL}"`
+	Mode        string `json:"{#PORT.MODE}"`
+}
+
+// LLDSecret represents a secret in the secret discovery.
+type LLDSecret struct {
+	ID   string `json:"{#SECRET.ID}"`
+	Name string `json:"{#SECRET.NAME}"`
+}
+
+// LLDConfig represents a config in the config discovery.
+type LLDConfig struct {
+	ID   string `json:"{#CONFIG.ID}"`
+	Name string `json:"{#CONFIG.NAME}"`
+}
+
+// LLDCertificate represents a config containing a certificate in the certificate discovery.
+type LLDCertificate struct {
+	ID   string `json:"{#CONFIG.ID}"`
+	Name string `json:"{#CONFIG.NAME}"`
+}
+
+// ServiceRecreation represents the re-creations of a service key observed since the plugin started.
+type ServiceRecreation struct {
+	Count         int      `json:"count"`
+	LastRecreated int64    `json:"last_recreated"`
+	CurrentID     string   `json:"current_id"`
+	PreviousIDs   []string `json:"previous_ids"`
+}
+
+// StackReplicas represents the replica counts summed over all services of a stack.
+type StackReplicas struct {
+	Desired int `json:"desired"`
+	Running int `json:"running"`
+	Unknown int `json:"unknown"`
+}
+
+// StackHealth represents the health status of a Docker stack.
 type StackHealth struct {
-	StackName         string  `json:"{#STACK.NAME}"`
-	TotalServices     int     `json:"total_services"`
-	HealthyServices   int     `json:"healthy_services"`
-	UnhealthyServices int     `json:"unhealthy_services"`
-	HealthPercentage  float64 `json:"health_percentage"`
+	StackName           string          `json:"stack_name"`
+	TotalServices       int             `json:"total_services"`
+	HealthyServices     int             `json:"healthy_services"`
+	UnhealthyServices   int             `json:"unhealthy_services"`
+	DegradedServices    int             `json:"degraded_services"`
+	DownServices        int             `json:"down_services"`
+	UnknownServices     int             `json:"unknown_services"`
+	MaintenanceServices int             `json:"maintenance_services"`
+	HealthPercentage    float64         `json:"health_percentage"`
+	Status              string          `json:"status"`
+	StatusCode          int             `json:"status_code"`
+	Services            []ServiceHealth `json:"services"`
+}
+
+// ServiceHealth represents the health status of a single service of a stack.
+type ServiceHealth struct {
+	Name     string  `json:"name"`
+	Key      string  `json:"key"`
+	Desired  int     `json:"desired"`
+	Running  int     `json:"running"`
+	Weight   float64 `json:"weight"`
+	Critical bool    `json:"critical"`
+	State    string  `json:"state"`
+	Reason   string  `json:"reason,omitempty"`
+}
+
+// Node represents a Docker Swarm node.
+type Node struct {
+	ID          string          `json:"ID"`
+	Description NodeDescription `json:"Description"`
+	Spec        NodeSpec        `json:"Spec"`
+	Status      NodeStatus      `json:"Status"`
+}
+
+// NodeDescription represents the properties reported by a node.
+type NodeDescription struct {
+	Hostname string `json:"Hostname"`
+}
+
+// NodeSpec represents the configured role and availability of a node.
+type NodeSpec struct {
+	Role         string `json:"Role"`
+	Availability string `json:"Availability"`
+}
+
+// NodeStatus represents the state of a node.
+type NodeStatus struct {
+	State string `json:"State"`
+}
+
+// Event represents a Docker event.
+type Event struct {
+	Type     string     `json:"Type"`
+	Action   string     `json:"Action"`
+	Actor    EventActor `json:"Actor"`
+	Time     int64      `json:"time"`
+	TimeNano int64      `json:"timeNano"`
+}
+
+// EventActor represents the object an event is about.
+type EventActor struct {
+	ID         string            `json:"ID"`
+	Attributes map[string]string `json:"Attributes"`
+}
+
+// ServiceEvents represents the recent events of a service.
+type ServiceEvents struct {
+	Count  int            `json:"count"`
+	Events []ServiceEvent `json:"events"`
+}
+
+// ServiceEvent represents a single service or container event of a service.
+type ServiceEvent struct {
+	Time     int64  `json:"time"`
+	Type     string `json:"type"`
+	Action   string `json:"action"`
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	ExitCode string `json:"exit_code,omitempty"`
+}
+
+// ServiceUptime represents the age in seconds of the running tasks of a service.
+type ServiceUptime struct {
+	Running int   `json:"running"`
+	Min     int64 `json:"min"`
+	Avg     int64 `json:"avg"`
+	Max     int64 `json:"max"`
+}
+
+// CrashLoop represents the crash loop state of a service.
+type CrashLoop struct {
+	CrashLoop   int    `json:"crashloop"`
+	Failures    int    `json:"failures"`
+	Backoff     int    `json:"backoff"`
+	LastFailure int64  `json:"last_failure"`
+	LastError   string `json:"last_error,omitempty"`
 }
 
 // ErrorMessage represents the API error message from Docker.
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+// taskStartTime returns the creation time of a task. Unlike the status timestamp it does not
+// change on status updates of a running task. The status timestamp is used if it is missing.
+func taskStartTime(task Task) (time.Time, bool) {
+	for _, ts := range []string{task.CreatedAt, task.Status.Timestamp} {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (p *swarmPlugin) getServiceUptime(_ context.Context, params []string) (any, error) {
+	if len(params) != 1 {
+		return nil, errs.New("expected 1 parameter for service uptime")
+	}
+
+	targetService, err := p.findServiceByIdentifier(params[0])
+	if err != nil {
+		return nil, err
+	}
+
+	filters := map[string][]string{
+		"service":       {targetService.ID},
+		"desired-state": {"running"},
+	}
+
+	tasks, err := p.getTasks(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	var (
+		uptime ServiceUptime
+		total  int64
+	)
+
+	for _, task := range tasks {
+		if task.Status.State != "running" {
+			continue
+		}
+
+		started, ok := taskStartTime(task)
+		if !ok {
+			continue
+		}
+
+		age := int64(now.Sub(started).Seconds())
+
+		if uptime.Running == 0 || age < uptime.Min {
+			uptime.Min = age
+		}
+
+		if age > uptime.Max {
+			uptime.Max = age
+		}
+
+		uptime.Running++
+		total += age
+	}
+
+	if uptime.Running > 0 {
+		uptime.Avg = total / int64(uptime.Running)
+	}
+
+	return jsonResult(uptime)
+}
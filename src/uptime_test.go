@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetServiceUptime(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPlugin(t, newFakeDocker(t, "swarm"))
+
+	res, err := p.getServiceUptime(context.Background(), []string{"web"})
+	if err != nil {
+		t.Fatalf("getServiceUptime() error = %v", err)
+	}
+
+	var uptime ServiceUptime
+	if err = decodeResult(res, &uptime); err != nil {
+		t.Fatalf("cannot decode result: %s", err)
+	}
+
+	// Ages of tasks created at 12:00:10 and 12:05:00
+	if uptime.Running != 2 || uptime.Max-uptime.Min != 290 || uptime.Avg != uptime.Min+145 {
+		t.Fatalf("getServiceUptime() = %+v, want 2 running tasks created 290s apart", uptime)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.zabbix.com/sdk/errs"
+)
+
+const (
+	// vanishedPolicyError makes items of a vanished service unsupported.
+	vanishedPolicyError = "error"
+	// vanishedPolicyNegative makes items of a vanished service return -1.
+	vanishedPolicyNegative = "negative"
+	// vanishedPolicyLast makes items of a vanished service return their last value.
+	vanishedPolicyLast = "last"
+)
+
+// trackedService is a service seen in a service listing, with the time it was found missing from
+// a later one once it vanished.
+type trackedService struct {
+	id        string
+	name      string
+	key       string
+	createdAt string
+	deletedAt time.Time
+}
+
+// trackService returns the identifiers of a service tracked between service listings.
+func (p *swarmPlugin) trackService(s Service) trackedService {
+	_, serviceKey := p.resolveService(s.Spec.Name, s.Spec.Labels)
+
+	return trackedService{id: s.ID, name: s.Spec.Name, key: serviceKey, createdAt: s.CreatedAt}
+}
+
+// matches reports whether identifier is the ID, name or service key of the service, or only the
+// one of them given by the prefix of the identifier.
+func (s *trackedService) matches(identifier string) bool {
+	kind, value := parseServiceIdentifier(identifier)
+
+	switch kind {
+	case identifierID:
+		return value == s.id
+	case identifierName:
+		return value == s.name
+	case identifierKey:
+		return value == s.key
+	default:
+		return value == s.id || value == s.name || value == s.key
+	}
+}
+
+// serviceTracker records the services present in consecutive service listings, the services that
+// vanished between them and the last values of the service items.
+type serviceTracker struct {
+	mu       sync.Mutex
+	known    map[string]trackedService
+	vanished []trackedService
+	values   map[string]itemValue
+	// identities maps service keys to the IDs the services had, for detecting re-creations.
+	identities map[string]*serviceIdentity
+}
+
+// itemValue is the last value of a service item.
+type itemValue struct {
+	identifier string
+	value      any
+}
+
+// observe compares a complete service listing with the previous one and records the services that
+// are no longer present. A service deployed again under the same name or key is no longer vanished.
+// Records older than the grace period are dropped along with the last values of their items.
+func (t *serviceTracker) observe(services []trackedService, gracePeriod time.Duration, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := make(map[string]trackedService, len(services))
+
+	for _, s := range services {
+		current[s.id] = s
+	}
+
+	t.recordIdentities(services, gracePeriod, now)
+
+	for id, s := range t.known {
+		if _, exists := current[id]; !exists {
+			s.deletedAt = now
+			t.vanished = append(t.vanished, s)
+		}
+	}
+
+	t.known = current
+
+	kept := t.vanished[:0]
+
+	for _, v := range t.vanished {
+		redeployed := false
+
+		for _, s := range current {
+			if s.name == v.name || s.key == v.key {
+				redeployed = true
+
+				break
+			}
+		}
+
+		if redeployed {
+			continue
+		}
+
+		if now.Sub(v.deletedAt) > gracePeriod {
+			t.dropValues(v)
+
+			continue
+		}
+
+		kept = append(kept, v)
+	}
+
+	t.vanished = kept
+}
+
+// vanishedAt returns the time a service with the given identifier was found missing.
+func (t *serviceTracker) vanishedAt(identifier string) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := len(t.vanished) - 1; i >= 0; i-- {
+		if t.vanished[i].matches(identifier) {
+			return t.vanished[i].deletedAt, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// storeValue records the last value of a service item.
+func (t *serviceTracker) storeValue(key swarmMetricKey, params []string, value any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(params) == 0 {
+		return
+	}
+
+	if t.values == nil {
+		t.values = map[string]itemValue{}
+	}
+
+	t.values[itemValueKey(key, params)] = itemValue{identifier: params[0], value: value}
+}
+
+// lastValue returns the last value of a service item.
+func (t *serviceTracker) lastValue(key swarmMetricKey, params []string) (any, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	v, ok := t.values[itemValueKey(key, params)]
+
+	return v.value, ok
+}
+
+// dropValues removes the last values of the items referring to a vanished service.
+func (t *serviceTracker) dropValues(v trackedService) {
+	for k, value := range t.values {
+		if v.matches(value.identifier) {
+			delete(t.values, k)
+		}
+	}
+}
+
+func itemValueKey(key swarmMetricKey, params []string) string {
+	return string(key) + "[" + strings.Join(params, "\x00") + "]"
+}
+
+// withVanishedPolicy wraps the handler of a numeric service item, whose first parameter is the
+// service identifier, to apply the configured policy when the service vanished within the grace
+// period. Services that never existed or vanished earlier always make the item unsupported.
+func (p *swarmPlugin) withVanishedPolicy(
+	key swarmMetricKey, handler func(ctx context.Context, params []string) (any, error),
+) func(ctx context.Context, params []string) (any, error) {
+	return func(ctx context.Context, params []string) (any, error) {
+		res, err := handler(ctx, params)
+		if err == nil {
+			p.services.storeValue(key, params, res)
+
+			return res, nil
+		}
+
+		if !isNotFound(err) {
+			return nil, err
+		}
+
+		deletedAt, vanished := p.services.vanishedAt(params[0])
+		if !vanished || time.Since(deletedAt) > p.vanishedGracePeriod() {
+			return nil, err
+		}
+
+		switch p.options.VanishedServicePolicy {
+		case vanishedPolicyNegative:
+			return -1, nil
+		case vanishedPolicyLast:
+			if value, ok := p.services.lastValue(key, params); ok {
+				return value, nil
+			}
+		}
+
+		return nil, err
+	}
+}
+
+func (p *swarmPlugin) vanishedGracePeriod() time.Duration {
+	return time.Duration(p.options.VanishedServiceGracePeriod) * time.Second
+}
+
+// validateVanishedPolicy returns an error for an unknown vanished service policy. Empty is the
+// default policy.
+func validateVanishedPolicy(policy string) error {
+	switch policy {
+	case "", vanishedPolicyError, vanishedPolicyNegative, vanishedPolicyLast:
+		return nil
+	default:
+		return errs.New("invalid vanished service policy " + policy + ", expected error, negative or last")
+	}
+}
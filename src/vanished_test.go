@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestVanishedServicePolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		policy  string
+		want    any
+		wantErr bool
+	}{
+		{vanishedPolicyError, nil, true},
+		{vanishedPolicyNegative, -1, false},
+		{vanishedPolicyLast, 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			t.Parallel()
+
+			f := newFakeDocker(t, "swarm")
+			p := newTestPlugin(t, f)
+			p.options.VanishedServicePolicy = tt.policy
+			p.options.VanishedServiceGracePeriod = 600
+			p.initMetrics()
+
+			handler := p.metrics[serviceReplicasDesired].handler
+
+			res, err := handler(context.Background(), []string{"mystack_web"})
+			if err != nil || res != 3 {
+				t.Fatalf("handler() = %v, %v, want 3", res, err)
+			}
+
+			f.removeService("svcweb0001")
+
+			res, err = handler(context.Background(), []string{"mystack_web"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("handler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if res != tt.want {
+				t.Fatalf("handler() = %v, want %v", res, tt.want)
+			}
+
+			// Services that never existed are not affected by the policy
+			if _, err = handler(context.Background(), []string{"missing"}); !isNotFound(err) {
+				t.Fatalf("handler() with unknown service: error = %v, want not found error", err)
+			}
+		})
+	}
+}
+
+func TestServiceTracker(t *testing.T) {
+	t.Parallel()
+
+	web := trackedService{id: "svcweb0001", name: "web", key: "mystack_web"}
+	db := trackedService{id: "svcdb00001", name: "db", key: "db"}
+
+	grace := 10 * time.Minute
+	now := time.Now()
+
+	var tracker serviceTracker
+
+	tracker.observe([]trackedService{web, db}, grace, now)
+	tracker.storeValue(serviceReplicasDesired, []string{"mystack_web"}, 3)
+	tracker.observe([]trackedService{db}, grace, now)
+
+	for _, identifier := range []string{"svcweb0001", "web", "mystack_web"} {
+		if deletedAt, ok := tracker.vanishedAt(identifier); !ok || !deletedAt.Equal(now) {
+			t.Errorf("vanishedAt(%s) = %v, %v, want %v", identifier, deletedAt, ok, now)
+		}
+	}
+
+	if _, ok := tracker.vanishedAt("db"); ok {
+		t.Error("vanishedAt(db): present service reported as vanished")
+	}
+
+	// Redeploying the service under a new ID ends the vanished state
+	redeployed := web
+	redeployed.id = "svcweb0002"
+
+	tracker.observe([]trackedService{db, redeployed}, grace, now)
+
+	if _, ok := tracker.vanishedAt("mystack_web"); ok {
+		t.Error("vanishedAt(mystack_web): redeployed service reported as vanished")
+	}
+
+	// Records and last values are dropped after the grace period
+	tracker.observe([]trackedService{db}, grace, now)
+	tracker.observe([]trackedService{db}, grace, now.Add(grace+time.Second))
+
+	if _, ok := tracker.vanishedAt("mystack_web"); ok {
+		t.Error("vanishedAt(mystack_web): record kept after the grace period")
+	}
+
+	if _, ok := tracker.lastValue(serviceReplicasDesired, []string{"mystack_web"}); ok {
+		t.Error("lastValue(mystack_web): value kept after the grace period")
+	}
+}